@@ -0,0 +1,90 @@
+package cdsapi
+
+import (
+	"net/http"
+	"time"
+)
+
+const defaultUserAgent = "cds-virtual-kubelet"
+
+// Client sends signed requests to the CDS OpenAPI gateway.
+type Client struct {
+	endpoint        string
+	accessKeyID     string
+	accessKeySecret string
+	httpClient      *http.Client
+	userAgent       string
+	retryPolicy     RetryPolicy
+	timeout         time.Duration
+	actionTimeouts  map[string]time.Duration
+	limiters        map[string]*actionLimiter
+	redactor        *Redactor
+	// defaultParams are added to every request unless the request sets them.
+	defaultParams map[string]string
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithEndpoint sets the OpenAPI gateway url.
+func WithEndpoint(endpoint string) ClientOption {
+	return func(c *Client) {
+		c.endpoint = endpoint
+	}
+}
+
+// WithCredentials sets the access key used to sign requests.
+func WithCredentials(accessKeyID, accessKeySecret string) ClientOption {
+	return func(c *Client) {
+		c.accessKeyID = accessKeyID
+		c.accessKeySecret = accessKeySecret
+	}
+}
+
+// WithHTTPClient sets the http client requests are sent with.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithDefaultParams adds query params (e.g. CustomerId, UserId) to every request.
+func WithDefaultParams(params map[string]string) ClientOption {
+	return func(c *Client) {
+		for k, v := range params {
+			if v != "" {
+				c.defaultParams[k] = v
+			}
+		}
+	}
+}
+
+// NewClient creates a Client, unset options fall back to a http client with
+// DefaultPoolOptions, DefaultTimeout, DefaultRedactor, the default user agent
+// and DefaultRetryPolicy.
+func NewClient(opts ...ClientOption) *Client {
+	c := &Client{
+		httpClient:     newPooledHTTPClient(DefaultPoolOptions()),
+		userAgent:      defaultUserAgent,
+		retryPolicy:    DefaultRetryPolicy(),
+		timeout:        DefaultTimeout,
+		redactor:       DefaultRedactor(),
+		actionTimeouts: make(map[string]time.Duration),
+		defaultParams:  make(map[string]string),
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+func (c *Client) IsAccessKeySet() bool {
+	return c.accessKeyID != "" && c.accessKeySecret != ""
+}
@@ -23,24 +23,11 @@ const (
 	timeStampFormat        = "2006-01-02T15:04:05Z"
 )
 
-var (
-	APIHost         string
-	AccessKeyID     string
-	AccessKeySecret string
-)
-
-func IsAccessKeySet() bool {
-	return AccessKeyID != "" && AccessKeySecret != ""
-}
-
+// PRE_IP, DEV_IP and CDS_OVERSEA stay environment only on purpose: they
+// patch /etc/hosts and resolv.conf of the image to reach the pre-release and
+// test gateways, which happens when the package loads, before the
+// configuration file is read.
 func init() {
-	if AccessKeyID == "" {
-		AccessKeyID = os.Getenv(accessKeyIdLiteral)
-	}
-	if AccessKeySecret == "" {
-		AccessKeySecret = os.Getenv(accessKeySecretLiteral)
-	}
-
 	// dnsDeal()
 	if preIp := os.Getenv("PRE_IP"); preIp != "" {
 		_, _ = Run("sh", "-c", fmt.Sprintf("echo '%s  cdsapi-gateway.gic.pre' >> /etc/hosts", preIp))
@@ -49,9 +36,6 @@ func init() {
 	if devIp := os.Getenv("DEV_IP"); devIp != "" {
 		_, _ = Run("sh", "-c", fmt.Sprintf("echo '%s  gateway.gic.test' >> /etc/hosts", devIp))
 	}
-
-	APIHost = os.Getenv("OPENAPI_HOST")
-
 }
 
 func dnsDeal() {
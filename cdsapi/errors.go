@@ -0,0 +1,119 @@
+package cdsapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is a failed OpenAPI call, either a http error status or a
+// business code other than success in the response envelope.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	RequestId  string
+	Action     string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s <%v>", e.Action, e.StatusCode)
+	if e.Code != "" {
+		msg += " " + e.Code
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.RequestId != "" {
+		msg += " (request id " + e.RequestId + ")"
+	}
+	return msg
+}
+
+// NotFound implements errdefs.ErrNotFound.
+func (e *APIError) NotFound() bool {
+	return e.StatusCode == http.StatusNotFound || codeContains(e.Code, "NotFound", "NotExist")
+}
+
+// InvalidInput implements errdefs.ErrInvalidInput.
+func (e *APIError) InvalidInput() bool {
+	if e.NotFound() || e.Conflict() || e.QuotaExceeded() || e.Throttled() {
+		return false
+	}
+	return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity ||
+		codeContains(e.Code, "Invalid", "Missing", "Param")
+}
+
+// Conflict reports that the resource already exists or is in a state that
+// does not allow the action.
+func (e *APIError) Conflict() bool {
+	return e.StatusCode == http.StatusConflict || codeContains(e.Code, "Conflict", "AlreadyExist", "Duplicate", "IncorrectStatus")
+}
+
+// QuotaExceeded reports that the account has no quota left for the action.
+func (e *APIError) QuotaExceeded() bool {
+	return codeContains(e.Code, "Quota", "Insufficient")
+}
+
+// Throttled reports that the gateway rejected the call for its rate.
+func (e *APIError) Throttled() bool {
+	return e.StatusCode == http.StatusTooManyRequests || codeContains(e.Code, "Throttl", "RateLimit", "TooManyRequests")
+}
+
+func codeContains(code string, parts ...string) bool {
+	code = strings.ToLower(code)
+	for _, p := range parts {
+		if strings.Contains(code, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
+
+// successCodes are the envelope codes of a successful call: the gateway
+// answers Success, bodies without an envelope carry no code at all. Any
+// other code is a failure whatever the http status.
+var successCodes = map[string]bool{
+	"":        true,
+	"success": true,
+}
+
+func isSuccessCode(code string) bool {
+	return successCodes[strings.ToLower(code)]
+}
+
+// AsAPIError returns the APIError in err's chain, following both errors.Unwrap
+// and Cause() as used by github.com/pkg/errors and errdefs.
+func AsAPIError(err error) (*APIError, bool) {
+	for err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			return apiErr, true
+		}
+		c, ok := err.(interface{ Cause() error })
+		if !ok {
+			return nil, false
+		}
+		err = c.Cause()
+	}
+	return nil, false
+}
+
+// IsConflict reports whether err is an APIError classified as a conflict.
+func IsConflict(err error) bool {
+	e, ok := AsAPIError(err)
+	return ok && e.Conflict()
+}
+
+// IsQuotaExceeded reports whether err is an APIError classified as quota exceeded.
+func IsQuotaExceeded(err error) bool {
+	e, ok := AsAPIError(err)
+	return ok && e.QuotaExceeded()
+}
+
+// IsThrottled reports whether err is an APIError classified as throttled.
+func IsThrottled(err error) bool {
+	e, ok := AsAPIError(err)
+	return ok && e.Throttled()
+}
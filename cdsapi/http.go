@@ -7,13 +7,14 @@ import (
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/trace"
 	"io"
 	"net/http"
 	"net/url"
-	"os"
 	"sort"
 	"strings"
 	"time"
@@ -25,17 +26,18 @@ type CloudRequest struct {
 	action      string
 	productType string
 	body        interface{}
+	// nonIdempotent requests are only retried when they cannot have reached the backend.
+	nonIdempotent bool
 }
 
-func NewCCKRequest(ctx context.Context, action, method string, params map[string]string, body interface{}) (*CloudRequest, error) {
+func (c *Client) NewCCKRequest(ctx context.Context, action, method string, params map[string]string, body interface{}) (*CloudRequest, error) {
 	if params == nil {
 		params = make(map[string]string)
 	}
-	if customerId := os.Getenv(CUSTOMER_ID); customerId != "" {
-		params["CustomerId"] = customerId
-	}
-	if userId := os.Getenv(USER_ID); userId != "" {
-		params["UserId"] = userId
+	for k, v := range c.defaultParams {
+		if _, ok := params[k]; !ok {
+			params[k] = v
+		}
 	}
 	return NewRequest(action, method, params, cckProductType, body), nil
 }
@@ -52,41 +54,95 @@ func NewRequest(action, method string, params map[string]string, productType str
 	return req
 }
 
-func DoOpenApiRequest(ctx context.Context, req *CloudRequest, staggered int) (resp *http.Response, err error) {
-	if !IsAccessKeySet() {
+// NonIdempotent marks the request as unsafe to repeat, e.g. a create.
+func (r *CloudRequest) NonIdempotent() *CloudRequest {
+	r.nonIdempotent = true
+	return r
+}
+
+func (c *Client) DoOpenApiRequest(ctx context.Context, req *CloudRequest, staggered int) (resp *http.Response, err error) {
+	if !c.IsAccessKeySet() {
 		return nil, fmt.Errorf("AccessKeyID or accessKeySecret is empty")
 	}
 	if staggered != 0 {
 		Staggered(staggered)
 	}
-	reqUrl := getUrl(req)
+	ctx, span := trace.StartSpan(ctx, "cdsapi."+req.action)
+	defer span.End()
+	defer func() {
+		span.SetStatus(err)
+	}()
+
 	b, _ := json.Marshal(req.body)
-	resp, err = DoRequest(req.method, reqUrl, bytes.NewReader(b))
+	var reqUrl string
+	for attempt := 1; ; attempt++ {
+		// every attempt is signed again, the nonce and timestamp must be fresh.
+		release, limitErr := c.waitLimiter(ctx, req.action)
+		if limitErr != nil {
+			return nil, limitErr
+		}
+		reqUrl = c.getUrl(req)
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if timeout := c.timeoutFor(req.action); timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		resp, err = c.DoRequest(attemptCtx, req.method, reqUrl, bytes.NewReader(b))
+		release()
+		if err != nil {
+			cancel()
+			err = c.redactURLError(err)
+		} else {
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+		}
+		if attempt >= c.retryPolicy.MaxAttempts || retryDisabled(ctx) || !c.retryPolicy.shouldRetry(req, resp, err) {
+			break
+		}
+		delay := c.retryPolicy.backoff(attempt)
+		if err != nil {
+			log.G(ctx).WithField("Action", req.action).Warn(fmt.Sprintf("attempt %v failed: %v, retry in %v", attempt, err, delay))
+		} else {
+			log.G(ctx).WithField("Action", req.action).Warn(fmt.Sprintf("attempt %v got code %v, retry in %v", attempt, resp.StatusCode, delay))
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if sleepErr := sleepCtx(ctx, delay); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
-	log.G(ctx).WithField("Action", req.action).Warn(reqUrl)
-	log.G(ctx).WithField("Action", req.action).Debug(fmt.Sprintf("code: %v, req: %v", resp.StatusCode, string(b)))
-	//if resp.StatusCode >= 400 {
-	//	log.G(ctx).WithField("Action", req.action).Warn(fmt.Sprintf("code: %v, req: %v", resp.StatusCode, string(b)))
-	//}
+	ctx = span.WithField(ctx, "url", c.redactor.URL(reqUrl))
+	log.G(ctx).WithField("Action", req.action).Debug(fmt.Sprintf("code: %v, req: %v", resp.StatusCode, c.redactor.JSON(b)))
 	return
 }
 
-func DoRequest(method, url string, body io.Reader) (resp *http.Response, err error) {
-	sendRequest, err := http.NewRequest(method, url, body)
+// redactURLError masks the signed url that net/http puts into its errors.
+func (c *Client) redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = c.redactor.URL(urlErr.URL)
+	}
+	return err
+}
+
+func (c *Client) DoRequest(ctx context.Context, method, url string, body io.Reader) (resp *http.Response, err error) {
+	sendRequest, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
 		return
 	}
 	sendRequest.Header.Set("Content-Type", " application/json")
-	resp, err = http.DefaultClient.Do(sendRequest)
+	if c.userAgent != "" {
+		sendRequest.Header.Set("User-Agent", c.userAgent)
+	}
+	resp, err = c.httpClient.Do(sendRequest)
 	return
 }
 
-func getUrl(req *CloudRequest) string {
+func (c *Client) getUrl(req *CloudRequest) string {
 	urlParams := map[string]string{
 		"Action":           req.action,
-		"AccessKeyId":      AccessKeyID,
+		"AccessKeyId":      c.accessKeyID,
 		"SignatureMethod":  signatureMethod,
 		"SignatureNonce":   uuid.New().String(),
 		"SignatureVersion": signatureVersion,
@@ -109,7 +165,7 @@ func getUrl(req *CloudRequest) string {
 	}
 	urlStr = req.method + "&%2F&" + percentEncode(urlStr[1:])
 
-	h := hmac.New(sha1.New, []byte(AccessKeySecret))
+	h := hmac.New(sha1.New, []byte(c.accessKeySecret))
 	h.Write([]byte(urlStr))
 	signStr := base64.StdEncoding.EncodeToString(h.Sum(nil))
 
@@ -120,7 +176,7 @@ func getUrl(req *CloudRequest) string {
 		urlVal.Add(k, v)
 	}
 	urlValStr := urlVal.Encode()
-	reqUrl := fmt.Sprintf("%s?%s", APIHost, urlValStr)
+	reqUrl := fmt.Sprintf("%s?%s", c.endpoint, urlValStr)
 	return reqUrl
 }
 
@@ -133,36 +189,53 @@ func percentEncode(str string) string {
 }
 
 type Response struct {
-	Code    string      `json:"code"`
-	Message string      `json:"msg"`
-	Data    interface{} `json:"data"`
+	Code      string      `json:"code"`
+	Message   string      `json:"msg"`
+	RequestId string      `json:"request_id"`
+	Data      interface{} `json:"data"`
 }
 
-func CdsRespDeal(ctx context.Context, response *http.Response, action string, data interface{}) (int, error) {
+// CdsRespDeal reads the response envelope into data. Failures, including a
+// non-success code in a 200 response, are returned as *APIError.
+func (c *Client) CdsRespDeal(ctx context.Context, response *http.Response, action string, data interface{}) (int, error) {
+	defer response.Body.Close()
 	content, err := io.ReadAll(response.Body)
 	if err != nil {
 		return 0, err
 	}
 
-	log.G(ctx).WithField("Action", action).Debug(string(content))
+	log.G(ctx).WithField("Action", action).Debug(c.redactor.JSON(content))
 
-	if response.StatusCode >= 400 {
-		log.G(ctx).WithField("Action", action).Error(string(content))
-		return response.StatusCode, fmt.Errorf("<%v>: %v", response.StatusCode, string(content))
-	}
 	var res Response
-	err = json.Unmarshal(content, &res)
-	if err != nil {
-		return 0, fmt.Errorf("%v", "resp json err")
+	jsonErr := json.Unmarshal(content, &res)
+	if response.StatusCode >= 400 || (jsonErr == nil && !isSuccessCode(res.Code)) {
+		apiErr := &APIError{
+			StatusCode: response.StatusCode,
+			Code:       res.Code,
+			Message:    res.Message,
+			RequestId:  res.RequestId,
+			Action:     action,
+		}
+		if apiErr.RequestId == "" {
+			apiErr.RequestId = response.Header.Get("X-Request-Id")
+		}
+		if jsonErr != nil {
+			apiErr.Message = c.redactor.JSON(content)
+		}
+		log.G(ctx).WithField("Action", action).Error(apiErr)
+		return response.StatusCode, apiErr
+	}
+	if jsonErr != nil {
+		return 0, fmt.Errorf("%s: resp json err: %v", action, jsonErr)
 	}
 	b, err := json.Marshal(res.Data)
 	if err != nil {
-		return 0, fmt.Errorf("%v", "resp json err")
+		return 0, fmt.Errorf("%s: resp json err: %v", action, err)
 	}
 	if data != nil {
 		err = json.Unmarshal(b, data)
 		if err != nil {
-			return 0, fmt.Errorf("%v", "resp json err")
+			return 0, fmt.Errorf("%s: resp json err: %v", action, err)
 		}
 	}
 	return response.StatusCode, nil
@@ -0,0 +1,126 @@
+package cdsapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"golang.org/x/time/rate"
+)
+
+// Action classes that get their own request budget, see actionClass.
+const (
+	CreateActionClass   = "Create"
+	DeleteActionClass   = "Delete"
+	DescribeActionClass = "Describe"
+)
+
+// limiterWaitWarn is the limiter wait above which a request is logged at warn level.
+const limiterWaitWarn = time.Second
+
+// Limit is the client side budget of one action class. A zero QPS or
+// MaxInFlight leaves that dimension unlimited.
+type Limit struct {
+	QPS         float64
+	Burst       int
+	MaxInFlight int
+}
+
+// WithRateLimits sets the budget per action class, actions outside the
+// given classes are not limited.
+func WithRateLimits(limits map[string]Limit) ClientOption {
+	return func(c *Client) {
+		c.limiters = make(map[string]*actionLimiter, len(limits))
+		for class, l := range limits {
+			c.limiters[class] = newActionLimiter(l)
+		}
+	}
+}
+
+// actionClass maps an OpenAPI action such as CreateContainerGroup to its budget.
+func actionClass(action string) string {
+	for _, class := range []string{CreateActionClass, DeleteActionClass, DescribeActionClass} {
+		if strings.HasPrefix(action, class) {
+			return class
+		}
+	}
+	return ""
+}
+
+type actionLimiter struct {
+	tokens   *rate.Limiter
+	inFlight chan struct{}
+}
+
+func newActionLimiter(l Limit) *actionLimiter {
+	al := &actionLimiter{}
+	if l.QPS > 0 {
+		burst := l.Burst
+		if burst < 1 {
+			burst = 1
+		}
+		al.tokens = rate.NewLimiter(rate.Limit(l.QPS), burst)
+	}
+	if l.MaxInFlight > 0 {
+		al.inFlight = make(chan struct{}, l.MaxInFlight)
+	}
+	return al
+}
+
+// acquire blocks until the request may be sent and returns the function that
+// frees its in-flight slot.
+func (l *actionLimiter) acquire(ctx context.Context) (func(), error) {
+	if l.tokens != nil {
+		if err := l.tokens.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if l.inFlight == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.inFlight <- struct{}{}:
+		return func() { <-l.inFlight }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+type noLimitKey struct{}
+
+// WithoutLimit lets DoOpenApiRequest calls under ctx skip the client side
+// rate limiter and in-flight cap, for rare probes that must not queue
+// behind the regular traffic.
+func WithoutLimit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noLimitKey{}, true)
+}
+
+func limitDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noLimitKey{}).(bool)
+	return disabled
+}
+
+// waitLimiter waits on the budget of action and reports the time spent in
+// the logs and the limiter wait metric.
+func (c *Client) waitLimiter(ctx context.Context, action string) (func(), error) {
+	l, ok := c.limiters[actionClass(action)]
+	if !ok || limitDisabled(ctx) {
+		return func() {}, nil
+	}
+	start := time.Now()
+	release, err := l.acquire(ctx)
+	waited := time.Since(start)
+	recordLimiterWait(ctx, action, waited)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for %s rate limiter: %v", action, err)
+	}
+	logger := log.G(ctx).WithField("Action", action)
+	if waited >= limiterWaitWarn {
+		logger.Warn(fmt.Sprintf("waited %v on rate limiter", waited))
+	} else if waited >= time.Millisecond {
+		logger.Debug(fmt.Sprintf("waited %v on rate limiter", waited))
+	}
+	return release, nil
+}
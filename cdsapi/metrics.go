@@ -0,0 +1,33 @@
+package cdsapi
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+var (
+	actionTagKey, _ = tag.NewKey("action")
+
+	limiterWaitMs = stats.Float64("cdsapi/limiter_wait", "Time an OpenAPI request waited on the client side rate limiter", stats.UnitMilliseconds)
+
+	// Views are the opencensus views of the cdsapi metrics, they are
+	// registered by the root command.
+	Views = []*view.View{
+		{
+			Name:        "cdsapi/limiter_wait",
+			Description: "Distribution of the time OpenAPI requests waited on the rate limiter",
+			Measure:     limiterWaitMs,
+			TagKeys:     []tag.Key{actionTagKey},
+			Aggregation: view.Distribution(0, 1, 5, 10, 50, 100, 500, 1000, 5000, 10000),
+		},
+	}
+)
+
+func recordLimiterWait(ctx context.Context, action string, waited time.Duration) {
+	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(actionTagKey, action)},
+		limiterWaitMs.M(float64(waited)/float64(time.Millisecond)))
+}
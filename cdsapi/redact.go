@@ -0,0 +1,143 @@
+package cdsapi
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+const redactedValue = "***"
+
+// defaultDenyFields are masked by DefaultRedactor. Plain names match a json
+// field or query param anywhere, dotted names match the end of a field path
+// with array indexes left out. The create payload does not tell secret files
+// and env values from configmap ones, so all of them are masked. Probe
+// headers often carry an Authorization token.
+var defaultDenyFields = []string{
+	"AccessKeyId",
+	"AccessKeySecret",
+	"Signature",
+	"password",
+	"auth",
+	"identity_token",
+	"registry_token",
+	"token",
+	"config_file_to_paths.content",
+	"environment_var.value",
+	"http_headers.value",
+}
+
+// Redactor masks credentials and secret contents in urls and json payloads
+// before they are logged or attached to trace spans.
+type Redactor struct {
+	deny  map[string]bool
+	allow map[string]bool
+}
+
+// NewRedactor creates a Redactor masking the deny fields, allow takes
+// precedence over deny. Names are matched case-insensitively.
+func NewRedactor(deny, allow []string) *Redactor {
+	r := &Redactor{
+		deny:  make(map[string]bool, len(deny)),
+		allow: make(map[string]bool, len(allow)),
+	}
+	for _, f := range deny {
+		r.deny[strings.ToLower(f)] = true
+	}
+	for _, f := range allow {
+		r.allow[strings.ToLower(f)] = true
+	}
+	return r
+}
+
+// DefaultRedactFields returns the fields DefaultRedactor masks.
+func DefaultRedactFields() []string {
+	return append([]string(nil), defaultDenyFields...)
+}
+
+// DefaultRedactor masks access keys, signatures, registry credentials and
+// config file contents.
+func DefaultRedactor() *Redactor {
+	return NewRedactor(defaultDenyFields, nil)
+}
+
+// WithRedactor sets the redactor applied to everything the client logs.
+func WithRedactor(r *Redactor) ClientOption {
+	return func(c *Client) {
+		c.redactor = r
+	}
+}
+
+func (r *Redactor) masked(name, path string) bool {
+	return !matchField(r.allow, name, path) && matchField(r.deny, name, path)
+}
+
+// matchField reports whether fields holds name or any dotted suffix of path.
+func matchField(fields map[string]bool, name, path string) bool {
+	if fields[strings.ToLower(name)] {
+		return true
+	}
+	path = strings.ToLower(path)
+	for {
+		if fields[path] {
+			return true
+		}
+		i := strings.Index(path, ".")
+		if i < 0 {
+			return false
+		}
+		path = path[i+1:]
+	}
+}
+
+// URL masks the denied query params of rawUrl.
+func (r *Redactor) URL(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return redactedValue
+	}
+	q := u.Query()
+	for k := range q {
+		if r.masked(k, k) {
+			q.Set(k, redactedValue)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// JSON masks the denied fields of a json document. Content that is not json
+// is returned unchanged.
+func (r *Redactor) JSON(b []byte) string {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return string(b)
+	}
+	out, err := json.Marshal(r.walk(v, ""))
+	if err != nil {
+		return redactedValue
+	}
+	return string(out)
+}
+
+func (r *Redactor) walk(v interface{}, path string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			if child != nil && r.masked(k, childPath) {
+				t[k] = redactedValue
+				continue
+			}
+			t[k] = r.walk(child, childPath)
+		}
+	case []interface{}:
+		for i, child := range t {
+			t[i] = r.walk(child, path)
+		}
+	}
+	return v
+}
@@ -0,0 +1,137 @@
+package cdsapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how DoOpenApiRequest retries failed attempts.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, values below 1 mean one attempt.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled on every retry up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter randomizes every delay by up to this fraction in either direction.
+	Jitter float64
+	// RetryableStatus lists the http status codes that are retried.
+	RetryableStatus map[int]bool
+	// RetryableError reports whether a transport error is retried.
+	RetryableError func(error) bool
+}
+
+// DefaultRetryPolicy retries throttling, 5xx gateway errors and connection
+// failures three times with 200ms..5s of backoff.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Jitter:      0.2,
+		RetryableStatus: map[int]bool{
+			http.StatusTooManyRequests:     true,
+			http.StatusInternalServerError: true,
+			http.StatusBadGateway:          true,
+			http.StatusServiceUnavailable:  true,
+			http.StatusGatewayTimeout:      true,
+		},
+		RetryableError: IsTransientError,
+	}
+}
+
+// WithRetryPolicy sets the retry policy of the client.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.retryPolicy = policy
+	}
+}
+
+type noRetryKey struct{}
+
+// WithoutRetry makes DoOpenApiRequest calls under ctx try only once, for
+// probes that must answer quickly rather than eventually.
+func WithoutRetry(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+func retryDisabled(ctx context.Context) bool {
+	disabled, _ := ctx.Value(noRetryKey{}).(bool)
+	return disabled
+}
+
+// IsTransientError reports whether err is a timeout, a reset or refused
+// connection, or an unexpected EOF from the gateway.
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return isDialError(err)
+}
+
+// isDialError reports whether err happened before the request was written,
+// so even a non-idempotent request can safely be sent again.
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// shouldRetry decides whether the attempt that produced resp/err is retried.
+// Non-idempotent requests are only retried when the gateway cannot have acted
+// on them: the connection was never made or the call was throttled.
+func (p RetryPolicy) shouldRetry(req *CloudRequest, resp *http.Response, err error) bool {
+	if err != nil {
+		if req.nonIdempotent {
+			return isDialError(err)
+		}
+		return p.RetryableError != nil && p.RetryableError(err)
+	}
+	if req.nonIdempotent {
+		return resp.StatusCode == http.StatusTooManyRequests
+	}
+	return p.RetryableStatus[resp.StatusCode]
+}
+
+// backoff returns the delay before retry number n, starting at 1.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d = time.Duration(float64(d) * (1 - p.Jitter + 2*p.Jitter*rand.Float64()))
+	}
+	return d
+}
+
+// sleepCtx waits for d, returning early with ctx's error once ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
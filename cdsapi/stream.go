@@ -0,0 +1,29 @@
+package cdsapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const streamHandshakeTimeout = 30 * time.Second
+
+// DialStream opens the websocket behind a streaming session (e.g. exec)
+// returned by the OpenAPI. The session url already carries its own token.
+func DialStream(ctx context.Context, sessionUrl string) (*websocket.Conn, error) {
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: streamHandshakeTimeout,
+	}
+	conn, resp, err := dialer.DialContext(ctx, sessionUrl, nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("<%v>: dial stream: %v", resp.StatusCode, err)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,95 @@
+package cdsapi
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout bounds a single attempt of an OpenAPI request, including
+// reading the response body.
+const DefaultTimeout = 30 * time.Second
+
+// PoolOptions tunes the connections to the OpenAPI gateway.
+type PoolOptions struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	KeepAlive           time.Duration
+	DialTimeout         time.Duration
+	TLSHandshakeTimeout time.Duration
+}
+
+// DefaultPoolOptions keeps enough idle connections to the single gateway
+// host for the pod sync workers.
+func DefaultPoolOptions() PoolOptions {
+	return PoolOptions{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+		KeepAlive:           30 * time.Second,
+		DialTimeout:         10 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+func newPooledHTTPClient(o PoolOptions) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   o.DialTimeout,
+				KeepAlive: o.KeepAlive,
+			}).DialContext,
+			MaxIdleConns:        o.MaxIdleConns,
+			MaxIdleConnsPerHost: o.MaxIdleConnsPerHost,
+			IdleConnTimeout:     o.IdleConnTimeout,
+			TLSHandshakeTimeout: o.TLSHandshakeTimeout,
+		},
+	}
+}
+
+// WithConnectionPool replaces the http client by one whose transport uses o.
+func WithConnectionPool(o PoolOptions) ClientOption {
+	return func(c *Client) {
+		c.httpClient = newPooledHTTPClient(o)
+	}
+}
+
+// WithTimeout sets the timeout of every attempt, zero disables it.
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithActionTimeouts overrides the timeout for single actions, e.g. a longer
+// one for CreateContainerGroup.
+func WithActionTimeouts(timeouts map[string]time.Duration) ClientOption {
+	return func(c *Client) {
+		for action, t := range timeouts {
+			c.actionTimeouts[action] = t
+		}
+	}
+}
+
+func (c *Client) timeoutFor(action string) time.Duration {
+	if t, ok := c.actionTimeouts[action]; ok {
+		return t
+	}
+	return c.timeout
+}
+
+// cancelOnClose releases the attempt context once the body has been consumed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -39,6 +39,8 @@ func main() {
 
 	var opts root.Opts
 	optsErr := root.SetDefaultOpts(&opts)
+	// flags override the environment, which overrides the config file.
+	root.LoadConfig(&opts, root.ConfigPathFromArgs(os.Args[1:]))
 	opts.Version = strings.Join([]string{"vk-cds", k8sVersion}, "-")
 
 	rootCmd := root.NewCommand(ctx, filepath.Base(os.Args[0]), opts)
@@ -1,10 +1,14 @@
 package eci
 
 type ContainerInfo struct {
-	Id              string           `json:"id,omitempty"`
-	Name            string           `json:"name"`
-	Image           string           `json:"image"`
-	ImageVersion    string           `json:"version"`
+	Id           string `json:"id,omitempty"`
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	ImageVersion string `json:"version"`
+	// ImageDigest pins the image, it takes precedence over the version.
+	ImageDigest string `json:"digest,omitempty"`
+	// ImageId is the digest of the image actually pulled, only set by the backend.
+	ImageId         string           `json:"image_id,omitempty"`
 	ImagePullPolicy string           `json:"image_pull_policy"`
 	WorkingDir      string           `json:"working_dir"`
 	Arg             []string         `json:"arg"`
@@ -14,9 +18,72 @@ type ContainerInfo struct {
 	Ports           []ContainerPort  `json:"ports"`
 	EnvironmentVars []EnvironmentVar `json:"environment_var"`
 	VolumeMounts    []VolumeMount    `json:"volume_mounts"`
-	RestartCount    int              `json:"restart_count,omitempty"`
-	PreviousState   *ContainerState  `json:"previous_state,omitempty"`
-	CurrentState    *ContainerState  `json:"current_state,omitempty"`
+	LivenessProbe   *Probe           `json:"liveness_probe,omitempty"`
+	ReadinessProbe  *Probe           `json:"readiness_probe,omitempty"`
+	SecurityContext *SecurityContext `json:"security_context,omitempty"`
+	Lifecycle       *Lifecycle       `json:"lifecycle,omitempty"`
+	// TerminationMessagePath is read when the container terminates and
+	// reported as the message of its terminated state.
+	TerminationMessagePath   string          `json:"termination_message_path,omitempty"`
+	TerminationMessagePolicy string          `json:"termination_message_policy,omitempty"`
+	RestartCount             int             `json:"restart_count,omitempty"`
+	PreviousState            *ContainerState `json:"previous_state,omitempty"`
+	CurrentState             *ContainerState `json:"current_state,omitempty"`
+	// Ready is the result of the readiness probe, only set by the backend.
+	Ready *bool `json:"ready,omitempty"`
+}
+
+type SecurityContext struct {
+	RunAsUser                *int64   `json:"run_as_user,omitempty"`
+	RunAsGroup               *int64   `json:"run_as_group,omitempty"`
+	RunAsNonRoot             bool     `json:"run_as_non_root"`
+	ReadOnlyRootFilesystem   bool     `json:"read_only_root_filesystem"`
+	AllowPrivilegeEscalation *bool    `json:"allow_privilege_escalation,omitempty"`
+	CapabilitiesAdd          []string `json:"capabilities_add,omitempty"`
+	CapabilitiesDrop         []string `json:"capabilities_drop,omitempty"`
+}
+
+type Lifecycle struct {
+	PostStart *Handler `json:"post_start,omitempty"`
+	PreStop   *Handler `json:"pre_stop,omitempty"`
+}
+
+// Handler is the action of a probe or lifecycle hook, exactly one of the fields is set.
+type Handler struct {
+	Exec      *ExecAction      `json:"exec,omitempty"`
+	HttpGet   *HttpGetAction   `json:"http_get,omitempty"`
+	TcpSocket *TcpSocketAction `json:"tcp_socket,omitempty"`
+}
+
+type ExecAction struct {
+	Command []string `json:"command"`
+}
+
+type HttpGetAction struct {
+	Path        string       `json:"path"`
+	Port        int          `json:"port"`
+	Host        string       `json:"host,omitempty"`
+	Scheme      string       `json:"scheme"`
+	HttpHeaders []HttpHeader `json:"http_headers,omitempty"`
+}
+
+type HttpHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type TcpSocketAction struct {
+	Port int    `json:"port"`
+	Host string `json:"host,omitempty"`
+}
+
+type Probe struct {
+	Handler
+	InitialDelaySeconds int32 `json:"initial_delay_seconds"`
+	TimeoutSeconds      int32 `json:"timeout_seconds"`
+	PeriodSeconds       int32 `json:"period_seconds"`
+	SuccessThreshold    int32 `json:"success_threshold"`
+	FailureThreshold    int32 `json:"failure_threshold"`
 }
 
 type ContainerState struct {
@@ -36,6 +103,9 @@ type ImageRegistryCredential struct {
 type EnvironmentVar struct {
 	Key   string `json:"key"`
 	Value string `json:"value"`
+	// FieldPath asks the backend to fill in a downward API field that is
+	// only known once the container group runs, e.g. status.podIP.
+	FieldPath string `json:"field_path,omitempty"`
 }
 
 type ContainerPort struct {
@@ -62,19 +132,42 @@ const (
 	VOL_TYPE_NFS              = "NFSVolume"
 	VOL_TYPE_EMPTYDIR         = "EmptyDirVolume"
 	VOL_TYPE_CONFIGFILEVOLUME = "ConfigFileVolume"
+	VOL_TYPE_PERSISTENT       = "PersistentVolume"
 )
 
 type Volume struct {
-	Type                 string             `json:"type"`
-	Name                 string             `json:"name"`
-	NfsVolumePath        string             `json:"nfs_volume_path"`
-	NfsVolumeServer      string             `json:"nfs_volume_server"`
-	NfsVolumeReadOnly    bool               `json:"nfs_volume_read_only"`
-	EmptyDirVolumeEnable bool               `json:"empty_dir_volume_enable"`
-	ConfigFileToPaths    []ConfigFileToPath `json:"config_file_to_paths"`
+	Type                 string `json:"type"`
+	Name                 string `json:"name"`
+	NfsVolumePath        string `json:"nfs_volume_path"`
+	NfsVolumeServer      string `json:"nfs_volume_server"`
+	NfsVolumeReadOnly    bool   `json:"nfs_volume_read_only"`
+	EmptyDirVolumeEnable bool   `json:"empty_dir_volume_enable"`
+	EmptyDirVolumeMedium string `json:"empty_dir_volume_medium,omitempty"`
+	// EmptyDirVolumeSizeLimit is in bytes, 0 is unlimited.
+	EmptyDirVolumeSizeLimit int64              `json:"empty_dir_volume_size_limit,omitempty"`
+	ConfigFileToPaths       []ConfigFileToPath `json:"config_file_to_paths"`
+	PersistentVolume        *PersistentVolume  `json:"persistent_volume,omitempty"`
+	// DefaultMode is the permission of files without their own mode, the
+	// modes are sent as plain integers, 0644 is 420.
+	DefaultMode int32 `json:"default_mode,omitempty"`
 }
 
+// PersistentVolume is a CDS disk, NAS or OSS volume provisioned by the CDS
+// CSI drivers and bound to a claim of the pod.
+type PersistentVolume struct {
+	Kind         string            `json:"kind"`
+	VolumeHandle string            `json:"volume_handle"`
+	FsType       string            `json:"fs_type,omitempty"`
+	ReadOnly     bool              `json:"read_only"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+}
+
+const CONTENT_ENCODING_BASE64 = "base64"
+
 type ConfigFileToPath struct {
 	Content string `json:"content"`
 	Path    string `json:"path"`
+	Mode    int32  `json:"mode,omitempty"`
+	// Encoding is base64 for content that is not valid utf-8.
+	Encoding string `json:"encoding,omitempty"`
 }
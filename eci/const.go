@@ -1,9 +1,13 @@
 package eci
 
 const (
-	CreateContainerGroupAction    = "CreateContainerGroup"
-	DeleteContainerGroupAction    = "DeleteContainerGroup"
-	DescribeContainerGroupsAction = "DescribeContainerGroups"
+	CreateContainerGroupAction          = "CreateContainerGroup"
+	DeleteContainerGroupAction          = "DeleteContainerGroup"
+	DescribeContainerGroupsAction       = "DescribeContainerGroups"
+	DescribeContainerLogAction          = "DescribeContainerLog"
+	DescribeContainerGroupMetricsAction = "DescribeContainerGroupMetrics"
+	ExecContainerCommandAction          = "ExecContainerCommand"
+	UpdateContainerGroupVolumeAction    = "UpdateContainerGroupVolume"
 )
 
 const (
@@ -2,4 +2,6 @@ package eci
 
 type DeleteContainerGroup struct {
 	ContainerGroupId string `json:"container_group_id"`
+	// GracePeriodSeconds overrides the grace period given at creation.
+	GracePeriodSeconds *int64 `json:"grace_period_seconds,omitempty"`
 }
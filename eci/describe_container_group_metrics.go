@@ -0,0 +1,51 @@
+package eci
+
+type DescribeContainerGroupMetricsRequest struct {
+	SiteId string `json:"site_id"`
+	NodeId string `json:"node_id"`
+}
+
+type ContainerGroupMetricsResp struct {
+	Metrics []ContainerGroupMetric `json:"metrics"`
+}
+
+type ContainerGroupMetric struct {
+	ContainerGroupId string            `json:"container_group_id"`
+	PodName          string            `json:"pod_name"`
+	Namespace        string            `json:"namespace"`
+	Timestamp        string            `json:"timestamp"`
+	Network          NetworkMetric     `json:"network"`
+	Containers       []ContainerMetric `json:"containers"`
+	// EphemeralStorage is the whole ephemeral disk, Volumes the emptyDirs on it.
+	EphemeralStorage StorageMetric  `json:"ephemeral_storage"`
+	Volumes          []VolumeMetric `json:"volumes"`
+}
+
+type StorageMetric struct {
+	CapacityBytes  uint64 `json:"capacity_bytes"`
+	AvailableBytes uint64 `json:"available_bytes"`
+	UsedBytes      uint64 `json:"used_bytes"`
+}
+
+type VolumeMetric struct {
+	Name      string `json:"name"`
+	UsedBytes uint64 `json:"used_bytes"`
+}
+
+type ContainerMetric struct {
+	Name                      string `json:"name"`
+	CpuUsageNanoCores         uint64 `json:"cpu_usage_nano_cores"`
+	CpuUsageCoreNanoSeconds   uint64 `json:"cpu_usage_core_nano_seconds"`
+	MemoryUsageBytes          uint64 `json:"memory_usage_bytes"`
+	MemoryWorkingSetBytes     uint64 `json:"memory_working_set_bytes"`
+	MemoryRssBytes            uint64 `json:"memory_rss_bytes"`
+	EphemeralStorageUsedBytes uint64 `json:"ephemeral_storage_used_bytes"`
+}
+
+type NetworkMetric struct {
+	Interface string `json:"interface"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxErrors  uint64 `json:"rx_errors"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxErrors  uint64 `json:"tx_errors"`
+}
@@ -1,7 +1,8 @@
 package eci
 
 type ContainerGroupResp struct {
-	Eci []ContainerGroup `json:"eci"`
+	Eci   []ContainerGroup `json:"eci"`
+	Total int              `json:"total"`
 }
 
 type ContainerGroup struct {
@@ -28,6 +29,7 @@ type ContainerGroup struct {
 type DescribeContainerGroupsRequest struct {
 	SiteId             string `json:"site_id"`
 	Limit              int    `json:"limit,omitempty"`
+	PageNumber         int    `json:"page_number,omitempty"`
 	NodeName           string `json:"node_name,omitempty"`
 	NodeId             string `json:"node_id,omitempty"`
 	ContainerGroupName string `json:"container_group_name,omitempty"`
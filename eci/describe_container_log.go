@@ -1,4 +1,18 @@
 package eci
 
 type DescribeContainerLogRequest struct {
+	ContainerGroupId string `json:"container_group_id"`
+	ContainerName    string `json:"container_name"`
+	Tail             int    `json:"tail,omitempty"`
+	SinceTime        string `json:"since_time,omitempty"`
+	Timestamps       bool   `json:"timestamps,omitempty"`
+	Previous         bool   `json:"previous,omitempty"`
+	LimitBytes       int    `json:"limit_bytes,omitempty"`
+	Offset           int64  `json:"offset,omitempty"`
+}
+
+type DescribeContainerLogResponse struct {
+	ContainerName string `json:"container_name"`
+	Content       string `json:"content"`
+	NextOffset    int64  `json:"next_offset"`
 }
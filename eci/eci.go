@@ -10,25 +10,64 @@ import (
 	"github.com/virtual-kubelet/virtual-kubelet/node/api"
 	"io"
 	v1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
 	"net/http"
-	"strings"
 	"sync"
+	"time"
 )
 
 // ECIProvider implements the virtual-kubelet provider interface and communicates with Alibaba Cloud's ECI APIs.
 type ECIProvider struct {
 	sync.RWMutex
+	client             *cdsapi.Client
 	resourceManager    *manager.ResourceManager
+	kubeClient         kubernetes.Interface
+	tokens             *tokenManager
 	nodeName           string
 	operatingSystem    string
-	cpu                string
-	memory             string
-	maxPods            string
 	createdPod         *sync.Map
 	internalIP         string
 	daemonEndpointPort int32
+	startTime          time.Time
+
+	// instance sizes pod requests are rounded up to, and the sizes of the
+	// pods created, keyed by namespace-name.
+	instanceSizes  []InstanceSize
+	defaultSize    InstanceSize
+	namespaceSizes map[string]InstanceSize
+	overhead       InstanceSize
+	podSizes       sync.Map
+
+	// capacity of the node
+	capacity v1.ResourceList
+
+	// DaemonSet pods that don't run stand in as placeholders, keyed by namespace-name.
+	daemonSetPolicy DaemonSetPolicy
+	daemonSetAllow  labels.Selector
+	strictSecurity  bool
+	storageTypes    map[string]bool
+	placeholders    sync.Map
+	notifier        func(*v1.Pod)
+
+	// orphans holds when container groups without a pod were first seen,
+	// only the reconciler goroutine touches them.
+	reconcile       ReconcileOptions
+	podsSynced      cache.InformerSynced
+	recorder        record.EventRecorder
+	orphans         map[string]time.Time
+	reportedOrphans map[string]bool
+
+	// podSnapshot holds the pods seen by the last status poll, keyed by namespace-name.
+	podSnapshot map[string]*v1.Pod
+
+	metricsSync     sync.Mutex
+	metricsSyncTime time.Time
+	lastMetric      *stats.Summary
 }
 
 // AuthConfig is the secret returned from an ImageRegistryCredential
@@ -43,38 +82,48 @@ type AuthConfig struct {
 }
 
 // NewECIProvider creates a new ECIProvider.
-func NewECIProvider(rm *manager.ResourceManager, nodeName, operatingSystem string, internalIP string, daemonEndpointPort int32) (*ECIProvider, error) {
+func NewECIProvider(client *cdsapi.Client, rm *manager.ResourceManager, kubeClient kubernetes.Interface, nodeName, operatingSystem string, internalIP string, daemonEndpointPort int32, opts ...ProviderOption) (*ECIProvider, error) {
 	var p ECIProvider
 	var err error
 
+	p.client = client
 	p.resourceManager = rm
+	p.kubeClient = kubeClient
+	p.tokens = newTokenManager(kubeClient.CoreV1())
 	p.createdPod = new(sync.Map)
+	p.startTime = time.Now()
 
-	p.cpu = "50000"
-	p.memory = "4Ti"
-	p.maxPods = MaxPods
+	p.capacity = DefaultCapacity()
 
 	p.operatingSystem = operatingSystem
 	p.nodeName = nodeName
 	p.internalIP = internalIP
 	p.daemonEndpointPort = daemonEndpointPort
 
+	p.defaultSize = DefaultInstanceSize
+	p.daemonSetPolicy = DaemonSetFake
+	p.reconcile = ReconcileOptions{Interval: DefaultReconcileInterval, GracePeriod: DefaultOrphanGracePeriod}
+	p.orphans = make(map[string]time.Time)
+	p.reportedOrphans = make(map[string]bool)
+	for _, opt := range opts {
+		opt(&p)
+	}
+	sortInstanceSizes(p.instanceSizes)
+
 	return &p, err
 }
 
 // CreatePod accepts a Pod definition and creates an ECI deployment
 func (p *ECIProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
-	if pod != nil && pod.OwnerReferences != nil && len(pod.OwnerReferences) != 0 && pod.OwnerReferences[0].Kind == "DaemonSet" {
-		return fmt.Errorf("%s DaemonSet unsupported", pod.Name)
+	if placeholder := p.placeholderFor(pod); placeholder != nil {
+		p.createPlaceholder(ctx, placeholder)
+		return nil
 	}
 	if pod.Status.Reason == "ProviderFailed" {
 		return fmt.Errorf("%s", pod.Status.Message)
 	}
 	log.G(ctx).WithField("CDS", "CreatePod").Debug(fmt.Sprintf("now created pod sum %v", getSyncMapLength(p.createdPod)))
 
-	if getSyncMapLength(p.createdPod) > 400 {
-		return fmt.Errorf("create fail")
-	}
 	var (
 		ownerMap = make(map[string]string)
 		// simContainers []map[string]string
@@ -85,13 +134,22 @@ func (p *ECIProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
 	}
 	request := CreateContainerGroup{}
 	request.RestartPolicy = string(pod.Spec.RestartPolicy)
+	request.TerminationGracePeriodSeconds = pod.Spec.TerminationGracePeriodSeconds
+
+	size, err := p.podInstanceSize(pod)
+	if err != nil {
+		return err
+	}
+	if err := p.checkSecurity(ctx, pod); err != nil {
+		return err
+	}
 
 	// get containers
-	containers, cpu, mem, err := p.getContainers(pod, false)
+	containers, err := p.getContainers(pod, false, size)
 	if err != nil {
 		return err
 	}
-	initContainers, icpu, imem, err := p.getContainers(pod, true)
+	initContainers, err := p.getContainers(pod, true, size)
 	if err != nil {
 		return err
 	}
@@ -101,7 +159,7 @@ func (p *ECIProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
 	}
 
 	// get registry creds
-	creds, err := p.getImagePullSecrets(pod)
+	creds, err := p.getImagePullSecrets(ctx, pod)
 	if err != nil {
 		return err
 	}
@@ -125,25 +183,30 @@ func (p *ECIProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
 	request.PodName = pod.Name
 	request.CreationTimestamp = pod.CreationTimestamp.UTC().Format(podTagTimeFormat)
 
-	request.Cpu, request.Memory = cpu+icpu, mem+imem
-	request.StorageType, request.StorageSize = makeStorageType(pod)
+	request.Cpu, request.Memory = size.Cpu, size.Memory
+	request.StorageType, request.StorageSize, err = p.podStorage(pod)
+	if err != nil {
+		return err
+	}
 
 	log.G(ctx).WithField("CDS", "CreatePod").Debug(fmt.Sprintf("create pod: %v, %v, %v, %v",
 		pod.Namespace, pod.Name, pod.Status.Phase, pod.Status.Reason))
 
-	cckRequest, _ := cdsapi.NewCCKRequest(ctx, CreateContainerGroupAction, http.MethodPost, nil, request)
-	response, err := cdsapi.DoOpenApiRequest(ctx, cckRequest, 0)
+	cckRequest, _ := p.client.NewCCKRequest(ctx, CreateContainerGroupAction, http.MethodPost, nil, request)
+	response, err := p.client.DoOpenApiRequest(ctx, cckRequest.NonIdempotent(), 0)
 	if err != nil {
 		log.G(ctx).WithField("Action", CreateContainerGroupAction).Error(err)
 		return err
 	}
-	_, err = cdsapi.CdsRespDeal(ctx, response, CreateContainerGroupAction, nil)
+	_, err = p.client.CdsRespDeal(ctx, response, CreateContainerGroupAction, nil)
 	if err != nil {
 		log.G(ctx).WithField("CDS", "CreatePod").Error(fmt.Sprintf("%s-%s: %v", pod.Namespace, pod.Name, err))
 
 		return err
 	}
 	p.createdPod.Store(pod.Namespace+"-"+pod.Name, "creating")
+	p.podSizes.Store(pod.Namespace+"-"+pod.Name, podSize{size: size, scheduled: scheduledRequest(pod)})
+	p.annotateInstanceSize(ctx, pod, size)
 	return nil
 }
 
@@ -151,6 +214,9 @@ func (p *ECIProvider) CreatePod(ctx context.Context, pod *v1.Pod) error {
 func (p *ECIProvider) UpdatePod(ctx context.Context, pod *v1.Pod) error {
 	log.G(ctx).WithField("CDS", "UpdatePod").Debug(
 		fmt.Sprintf("update pod: %v, %v, %v, %v", pod.Name, pod.Namespace, pod.Status.Phase, pod.Status.Reason))
+	if _, ok := p.getPlaceholder(pod.Namespace, pod.Name); ok || p.isPlaceholder(pod) {
+		return nil
+	}
 	if pod.Status.Phase == v1.PodRunning {
 		p.createdPod.Store(pod.Namespace+"-"+pod.Name, "running")
 	}
@@ -199,12 +265,16 @@ func (p *ECIProvider) DeletePod(ctx context.Context, pod *v1.Pod) error {
 	if pod.Annotations != nil {
 		eciId = pod.Annotations["eci-instance-id"]
 	}
-	p.createdPod.Delete(pod.Namespace + "-" + pod.Name)
+	if p.deletePlaceholder(pod.Namespace, pod.Name) {
+		p.forgetPod(pod)
+		return nil
+	}
 	if eciId == "" {
-		cgs, code, err := p.GetCgs(ctx, pod.Namespace, pod.Name)
-		if err != nil || code >= 400 {
-			log.G(ctx).WithField("CDS", "DeletePod").Debug(
-				fmt.Sprintf("get cg error: %v %v", code, err))
+		cgs, _, err := p.GetCgs(ctx, pod.Namespace, pod.Name)
+		if err != nil && !errdefs.IsNotFound(err) {
+			// retry later rather than report the group as gone and leak it.
+			log.G(ctx).WithField("CDS", "DeletePod").Error(fmt.Sprintf("get cg error: %v", err))
+			return err
 		}
 		if len(cgs) == 1 {
 			eciId = cgs[0].ContainerGroupId
@@ -219,30 +289,45 @@ func (p *ECIProvider) DeletePod(ctx context.Context, pod *v1.Pod) error {
 	if eciId == "" {
 		log.G(ctx).WithField("CDS", "DeletePod").Error(
 			fmt.Sprintf("can't find Pod %s id", pod.Name))
+		p.forgetPod(pod)
 		return errdefs.NotFoundf(" can't find Pod %s", pod.Name)
 	}
-	cckRequest, _ := cdsapi.NewCCKRequest(ctx, DeleteContainerGroupAction, http.MethodPost, nil,
-		DeleteContainerGroup{ContainerGroupId: eciId})
-	response, err := cdsapi.DoOpenApiRequest(ctx, cckRequest, 0)
+	err := p.deleteContainerGroup(ctx, eciId, pod.DeletionGracePeriodSeconds)
+	if err != nil {
+		log.G(ctx).WithField("CDS", "DeletePod").Error(fmt.Sprintf("%s-%s: %v", pod.Namespace, pod.Name, err))
+		return err
+	}
+	p.forgetPod(pod)
+	return nil
+}
+
+// forgetPod drops what CreatePod remembered about a pod once its group is
+// gone, a failed delete keeps it so the retry still sees the pod.
+func (p *ECIProvider) forgetPod(pod *v1.Pod) {
+	p.createdPod.Delete(pod.Namespace + "-" + pod.Name)
+	p.podSizes.Delete(pod.Namespace + "-" + pod.Name)
+}
+
+// deleteContainerGroup deletes a container group, one that is already gone
+// counts as deleted.
+func (p *ECIProvider) deleteContainerGroup(ctx context.Context, id string, gracePeriodSeconds *int64) error {
+	cckRequest, _ := p.client.NewCCKRequest(ctx, DeleteContainerGroupAction, http.MethodPost, nil,
+		DeleteContainerGroup{ContainerGroupId: id, GracePeriodSeconds: gracePeriodSeconds})
+	response, err := p.client.DoOpenApiRequest(ctx, cckRequest, 0)
 	if err != nil {
 		log.G(ctx).WithField("Action", DeleteContainerGroupAction).Error(err)
-		if response != nil {
-			if response.StatusCode >= 400 && response.StatusCode < 500 {
-				return nil
-			}
-		}
 		return err
 	}
-	content, _ := io.ReadAll(response.Body)
-	log.G(ctx).WithField("Action", DeleteContainerGroupAction).Debug(string(content))
+	_, err = p.client.CdsRespDeal(ctx, response, DeleteContainerGroupAction, nil)
+	if err != nil && !errdefs.IsNotFound(err) {
+		return err
+	}
 	return nil
 }
 
 func (p *ECIProvider) GetPod(ctx context.Context, namespace, name string) (*v1.Pod, error) {
-	if strings.Contains(name, "disk-csi-cds-node") ||
-		strings.Contains(name, "nas-csi-cds-node") ||
-		strings.Contains(name, "oss-csi-cds-node") {
-		return nil, nil
+	if placeholder, ok := p.getPlaceholder(namespace, name); ok {
+		return placeholder, nil
 	}
 	pod, err := p.GetPodByCondition(ctx, "K8s-GetPod", namespace, name)
 	if err != nil {
@@ -254,34 +339,27 @@ func (p *ECIProvider) GetPod(ctx context.Context, namespace, name string) (*v1.P
 
 // GetContainerLogs returns the logs of a pod by name that is running inside ECI.
 func (p *ECIProvider) GetContainerLogs(ctx context.Context, namespace, podName, containerName string, opts api.ContainerLogOpts) (io.ReadCloser, error) {
-	logContent := "todo "
-	eciId := ""
-	cgs, _, _ := p.GetCgs(ctx, namespace, podName)
-	if len(cgs) == 1 {
-		eciId = cgs[0].ContainerGroupId
-	}
-	if eciId == "" {
-		return nil, errdefs.NotFoundf("GetContainerLogs can't find Pod %s", podName)
-	}
-	logContent += eciId
-	return io.NopCloser(strings.NewReader(logContent)), nil
-}
-
-// RunInContainer executes a command in a container in the pod, copying data
-// between in/out/err and the container's stdin/stdout/stderr.
-func (p *ECIProvider) RunInContainer(ctx context.Context, namespace, podName, containerName string, cmd []string, attach api.AttachIO) error {
-	return nil
+	return p.StreamContainerLogs(ctx, namespace, podName, containerName, ContainerLogOpts{
+		Tail:         opts.Tail,
+		SinceSeconds: int(opts.Since.Seconds()),
+		Timestamps:   opts.Timestamps,
+		LimitBytes:   opts.LimitBytes,
+	})
 }
 
 // GetPodStatus returns the status of a pod by name that is running inside ECI
 // returns nil if a pod by that name is not found.
 func (p *ECIProvider) GetPodStatus(ctx context.Context, namespace, name string) (*v1.PodStatus, error) {
-	if strings.Contains(name, "disk-csi-cds-node") ||
-		strings.Contains(name, "nas-csi-cds-node") ||
-		strings.Contains(name, "oss-csi-cds-node") {
-		return nil, fmt.Errorf("invalid pod")
+	if placeholder, ok := p.getPlaceholder(namespace, name); ok {
+		return &placeholder.Status, nil
+	}
+	if pod, ok := p.snapshotPod(namespace, name); ok {
+		return &pod.Status, nil
 	}
 	pod, err := p.GetPodByCondition(ctx, "Provider-GetPodStatus", namespace, name)
+	if cdsapi.IsThrottled(err) {
+		return nil, err
+	}
 	if err != nil || pod == nil {
 		log.G(ctx).WithField("CDS", "GetPodStatus").Error(fmt.Sprintf("%s-%s status err: %s", namespace, name, err))
 
@@ -303,7 +381,7 @@ func (p *ECIProvider) GetPodStatus(ctx context.Context, namespace, name string)
 // GetPods returns a list of all pods known to be running within ECI.
 func (p *ECIProvider) GetPods(ctx context.Context) ([]*v1.Pod, error) {
 	pods := make([]*v1.Pod, 0)
-	cgs, _, err := p.GetCgs(ctx, "", "")
+	cgs, err := p.listAllCgs(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -317,17 +395,12 @@ func (p *ECIProvider) GetPods(ctx context.Context) ([]*v1.Pod, error) {
 		}
 		pods = append(pods, pod)
 	}
-	return pods, nil
+	return append(pods, p.listPlaceholders()...), nil
 }
 
 // Capacity returns a resource list containing the capacity limits set for ECI.
 func (p *ECIProvider) Capacity(ctx context.Context) v1.ResourceList {
-	return v1.ResourceList{
-		"cpu":               resource.MustParse(p.cpu),
-		"memory":            resource.MustParse(p.memory),
-		"pods":              resource.MustParse(p.maxPods),
-		"ephemeral-storage": resource.MustParse("40Ti"),
-	}
+	return p.capacity.DeepCopy()
 }
 
 // NodeConditions returns a list of conditions (Ready, OutOfDisk, etc), for updates to the node status
@@ -0,0 +1,56 @@
+package eci
+
+import (
+	"context"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DefaultCapacity is the capacity of the node when nothing else is configured.
+func DefaultCapacity() v1.ResourceList {
+	return v1.ResourceList{
+		v1.ResourceCPU:              resource.MustParse("50000"),
+		v1.ResourceMemory:           resource.MustParse("4Ti"),
+		v1.ResourcePods:             resource.MustParse("1000"),
+		v1.ResourceEphemeralStorage: resource.MustParse("40Ti"),
+	}
+}
+
+// WithCapacity overrides the resources of the default capacity it names.
+func WithCapacity(capacity v1.ResourceList) ProviderOption {
+	return func(p *ECIProvider) {
+		for name, q := range capacity {
+			p.capacity[name] = q.DeepCopy()
+		}
+	}
+}
+
+// Allocatable is the capacity less what rounding pods up to instance sizes
+// costs beyond the requests the scheduler accounts for.
+func (p *ECIProvider) Allocatable(ctx context.Context) v1.ResourceList {
+	allocatable := p.Capacity(ctx)
+	var rounding InstanceSize
+	p.podSizes.Range(func(_, v interface{}) bool {
+		s := v.(podSize)
+		rounding.Cpu += s.size.Cpu - s.scheduled.Cpu
+		rounding.Memory += s.size.Memory - s.scheduled.Memory
+		return true
+	})
+	if q, ok := allocatable[v1.ResourceCPU]; ok && rounding.Cpu > 0 {
+		q.Sub(cpuQuantity(rounding.Cpu))
+		allocatable[v1.ResourceCPU] = q
+	}
+	if q, ok := allocatable[v1.ResourceMemory]; ok && rounding.Memory > 0 {
+		q.Sub(memoryQuantity(rounding.Memory))
+		allocatable[v1.ResourceMemory] = q
+	}
+	return allocatable
+}
+
+func cpuQuantity(cores float64) resource.Quantity {
+	return *resource.NewMilliQuantity(int64(cores*1000), resource.DecimalSI)
+}
+
+func memoryQuantity(gibs float64) resource.Quantity {
+	return *resource.NewQuantity(int64(gibs*gib), resource.BinarySI)
+}
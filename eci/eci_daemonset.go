@@ -0,0 +1,140 @@
+package eci
+
+import (
+	"context"
+	"fmt"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// DaemonSetPolicy decides what happens to DaemonSet pods scheduled to the
+// virtual node, which has no host to run node agents on.
+type DaemonSetPolicy string
+
+const (
+	// DaemonSetReject fails the pod with a terminal ProviderFailed status.
+	// The DaemonSet controller replaces failed pods, so node agents that
+	// tolerate every taint, such as CSI node plugins, churn forever.
+	DaemonSetReject DaemonSetPolicy = "reject"
+	// DaemonSetFake reports the pod Running without creating a container
+	// group. It is the default, it keeps DaemonSets quiet on the node.
+	DaemonSetFake DaemonSetPolicy = "fake"
+)
+
+// ParseDaemonSetPolicy validates a policy name.
+func ParseDaemonSetPolicy(s string) (DaemonSetPolicy, error) {
+	switch p := DaemonSetPolicy(s); p {
+	case DaemonSetReject, DaemonSetFake:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid DaemonSet policy %q, expected %s or %s", s, DaemonSetReject, DaemonSetFake)
+}
+
+// WithDaemonSetPolicy sets the policy of DaemonSet pods, pods matching allow
+// run as regular container groups instead. A nil allow matches nothing.
+func WithDaemonSetPolicy(policy DaemonSetPolicy, allow labels.Selector) ProviderOption {
+	return func(p *ECIProvider) {
+		p.daemonSetPolicy = policy
+		p.daemonSetAllow = allow
+	}
+}
+
+func isDaemonSetPod(pod *v1.Pod) bool {
+	for _, ref := range pod.OwnerReferences {
+		if ref.Kind == "DaemonSet" {
+			return true
+		}
+	}
+	return false
+}
+
+// isPlaceholder reports whether pod is a DaemonSet pod that is not allowed
+// to run, it never has a container group.
+func (p *ECIProvider) isPlaceholder(pod *v1.Pod) bool {
+	if !isDaemonSetPod(pod) {
+		return false
+	}
+	return p.daemonSetAllow == nil || !p.daemonSetAllow.Matches(labels.Set(pod.Labels))
+}
+
+// placeholderFor returns the placeholder standing in for a DaemonSet pod
+// that is not allowed to run, nil if the pod runs as a container group.
+func (p *ECIProvider) placeholderFor(pod *v1.Pod) *v1.Pod {
+	if !p.isPlaceholder(pod) {
+		return nil
+	}
+
+	now := metav1.Now()
+	placeholder := pod.DeepCopy()
+	status := v1.PodStatus{
+		HostIP:    p.internalIP,
+		StartTime: &now,
+	}
+	switch p.daemonSetPolicy {
+	case DaemonSetFake:
+		status.Phase = v1.PodRunning
+		// nothing listens for the pod, so it must not become a Service
+		// endpoint. Only host network pods share the node IP.
+		if pod.Spec.HostNetwork {
+			status.PodIP = p.internalIP
+		}
+		for _, t := range []v1.PodConditionType{v1.PodScheduled, v1.PodInitialized, v1.ContainersReady, v1.PodReady} {
+			status.Conditions = append(status.Conditions, v1.PodCondition{Type: t, Status: v1.ConditionTrue, LastTransitionTime: now})
+		}
+		for _, c := range pod.Spec.Containers {
+			status.ContainerStatuses = append(status.ContainerStatuses, v1.ContainerStatus{
+				Name:  c.Name,
+				Image: c.Image,
+				Ready: true,
+				State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: now}},
+			})
+		}
+	default:
+		status.Phase = v1.PodFailed
+		status.Reason = "ProviderFailed"
+		status.Message = "DaemonSet pods are not run on the virtual node"
+	}
+	placeholder.Status = status
+	return placeholder
+}
+
+// createPlaceholder records a placeholder and reports its status right away,
+// nothing else would as it has no container group.
+func (p *ECIProvider) createPlaceholder(ctx context.Context, placeholder *v1.Pod) {
+	log.G(ctx).WithField("CDS", "CreatePod").Info(fmt.Sprintf("DaemonSet pod %s-%s is a placeholder: %s",
+		placeholder.Namespace, placeholder.Name, placeholder.Status.Phase))
+	p.placeholders.Store(placeholder.Namespace+"-"+placeholder.Name, placeholder)
+	p.RLock()
+	notifier := p.notifier
+	p.RUnlock()
+	if notifier != nil {
+		notifier(placeholder)
+	}
+}
+
+func (p *ECIProvider) getPlaceholder(namespace, name string) (*v1.Pod, bool) {
+	v, ok := p.placeholders.Load(namespace + "-" + name)
+	if !ok {
+		return nil, false
+	}
+	return v.(*v1.Pod), true
+}
+
+// deletePlaceholder forgets a placeholder, reporting whether there was one.
+func (p *ECIProvider) deletePlaceholder(namespace, name string) bool {
+	key := namespace + "-" + name
+	_, ok := p.placeholders.Load(key)
+	p.placeholders.Delete(key)
+	return ok
+}
+
+func (p *ECIProvider) listPlaceholders() []*v1.Pod {
+	var pods []*v1.Pod
+	p.placeholders.Range(func(_, v interface{}) bool {
+		pods = append(pods, v.(*v1.Pod))
+		return true
+	})
+	return pods
+}
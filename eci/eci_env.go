@@ -0,0 +1,299 @@
+package eci
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/kubernetes/third_party/forked/golang/expansion"
+	"math"
+	"sort"
+	"strings"
+)
+
+// podIPFieldPaths are only known once the container group is running, the
+// backend fills them in from the field path.
+var podIPFieldPaths = map[string]bool{
+	"status.podIP":  true,
+	"status.podIPs": true,
+}
+
+// getEnvironmentVars resolves the env and envFrom of container the way the
+// kubelet does: envFrom first, env entries override them, and $(VAR)
+// references are expanded against the variables defined before. limit is
+// the cpu and memory the container may use, reported for unset limits.
+func (p *ECIProvider) getEnvironmentVars(pod *v1.Pod, container *v1.Container, limit InstanceSize) ([]EnvironmentVar, error) {
+	envs := make([]EnvironmentVar, 0, len(container.Env))
+	index := make(map[string]int)
+	set := func(env EnvironmentVar) {
+		if i, ok := index[env.Key]; ok {
+			envs[i] = env
+			return
+		}
+		index[env.Key] = len(envs)
+		envs = append(envs, env)
+	}
+
+	for _, from := range container.EnvFrom {
+		data, err := p.envFromSource(pod, from)
+		if err != nil {
+			return nil, err
+		}
+		// sorted so the request is the same on every call.
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			key := from.Prefix + k
+			if errs := validation.IsEnvVarName(key); len(errs) != 0 {
+				// the kubelet skips invalid keys as well.
+				continue
+			}
+			set(EnvironmentVar{Key: key, Value: data[k]})
+		}
+	}
+
+	// unresolved references are kept as written, like the kubelet does.
+	mapping := func(name string) string {
+		if i, ok := index[name]; ok {
+			return envs[i].Value
+		}
+		return "$(" + name + ")"
+	}
+	for _, e := range container.Env {
+		env := EnvironmentVar{Key: e.Name}
+		switch {
+		case e.ValueFrom == nil:
+			env.Value = expansion.Expand(e.Value, mapping)
+		case e.ValueFrom.FieldRef != nil:
+			fieldPath := e.ValueFrom.FieldRef.FieldPath
+			value, err := p.podFieldValue(pod, fieldPath)
+			if err != nil {
+				return nil, fmt.Errorf("env %s of container %s in Pod %s: %v", e.Name, container.Name, pod.Name, err)
+			}
+			if value == "" && podIPFieldPaths[fieldPath] {
+				env.FieldPath = fieldPath
+			}
+			env.Value = value
+		case e.ValueFrom.ResourceFieldRef != nil:
+			value, err := containerResourceValue(container, limit, e.ValueFrom.ResourceFieldRef)
+			if err != nil {
+				return nil, fmt.Errorf("env %s of container %s in Pod %s: %v", e.Name, container.Name, pod.Name, err)
+			}
+			env.Value = value
+		case e.ValueFrom.ConfigMapKeyRef != nil:
+			ref := e.ValueFrom.ConfigMapKeyRef
+			value, ok, err := p.configMapKey(pod, ref.Name, ref.Key, ref.Optional)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			env.Value = value
+		case e.ValueFrom.SecretKeyRef != nil:
+			ref := e.ValueFrom.SecretKeyRef
+			value, ok, err := p.secretKey(pod, ref.Name, ref.Key, ref.Optional)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			env.Value = value
+		default:
+			return nil, fmt.Errorf("env %s of container %s in Pod %s has an unsupported valueFrom", e.Name, container.Name, pod.Name)
+		}
+		set(env)
+	}
+	return envs, nil
+}
+
+// envFromSource returns the key/values of a configMapRef or secretRef.
+func (p *ECIProvider) envFromSource(pod *v1.Pod, from v1.EnvFromSource) (map[string]string, error) {
+	switch {
+	case from.ConfigMapRef != nil:
+		ref := from.ConfigMapRef
+		configMap, err := p.resourceManager.GetConfigMap(ref.Name, pod.Namespace)
+		if err != nil {
+			if k8serr.IsNotFound(err) && isOptional(ref.Optional) {
+				return nil, nil
+			}
+			return nil, refError("ConfigMap", ref.Name, pod, err)
+		}
+		return configMap.Data, nil
+	case from.SecretRef != nil:
+		ref := from.SecretRef
+		secret, err := p.resourceManager.GetSecret(ref.Name, pod.Namespace)
+		if err != nil {
+			if k8serr.IsNotFound(err) && isOptional(ref.Optional) {
+				return nil, nil
+			}
+			return nil, refError("Secret", ref.Name, pod, err)
+		}
+		data := make(map[string]string, len(secret.Data))
+		for k, v := range secret.Data {
+			data[k] = string(v)
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+// configMapKey returns key of the configmap name, ok is false when an optional
+// reference can't be resolved.
+func (p *ECIProvider) configMapKey(pod *v1.Pod, name, key string, optional *bool) (string, bool, error) {
+	configMap, err := p.resourceManager.GetConfigMap(name, pod.Namespace)
+	if err != nil {
+		if k8serr.IsNotFound(err) && isOptional(optional) {
+			return "", false, nil
+		}
+		return "", false, refError("ConfigMap", name, pod, err)
+	}
+	if value, ok := configMap.Data[key]; ok {
+		return value, true, nil
+	}
+	if value, ok := configMap.BinaryData[key]; ok {
+		return string(value), true, nil
+	}
+	if isOptional(optional) {
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("key %s of ConfigMap %s is required by Pod %s and does not exist", key, name, pod.Name)
+}
+
+// secretKey returns key of the secret name, ok is false when an optional
+// reference can't be resolved.
+func (p *ECIProvider) secretKey(pod *v1.Pod, name, key string, optional *bool) (string, bool, error) {
+	secret, err := p.resourceManager.GetSecret(name, pod.Namespace)
+	if err != nil {
+		if k8serr.IsNotFound(err) && isOptional(optional) {
+			return "", false, nil
+		}
+		return "", false, refError("Secret", name, pod, err)
+	}
+	if value, ok := secret.Data[key]; ok {
+		return string(value), true, nil
+	}
+	if isOptional(optional) {
+		return "", false, nil
+	}
+	return "", false, fmt.Errorf("key %s of Secret %s is required by Pod %s and does not exist", key, name, pod.Name)
+}
+
+func refError(kind, name string, pod *v1.Pod, err error) error {
+	if k8serr.IsNotFound(err) {
+		return fmt.Errorf("%s %s is required by Pod %s and does not exist", kind, name, pod.Name)
+	}
+	return fmt.Errorf("get %s %s for Pod %s: %v", kind, name, pod.Name, err)
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+// podFieldValue returns the downward API field of pod.
+func (p *ECIProvider) podFieldValue(pod *v1.Pod, fieldPath string) (string, error) {
+	if key, ok := subscript(fieldPath, "metadata.labels"); ok {
+		return pod.Labels[key], nil
+	}
+	if key, ok := subscript(fieldPath, "metadata.annotations"); ok {
+		return pod.Annotations[key], nil
+	}
+	switch fieldPath {
+	case "metadata.name":
+		return pod.Name, nil
+	case "metadata.namespace":
+		return pod.Namespace, nil
+	case "metadata.uid":
+		return string(pod.UID), nil
+	case "metadata.labels":
+		return formatMap(pod.Labels), nil
+	case "metadata.annotations":
+		return formatMap(pod.Annotations), nil
+	case "spec.nodeName":
+		if pod.Spec.NodeName != "" {
+			return pod.Spec.NodeName, nil
+		}
+		return p.nodeName, nil
+	case "spec.serviceAccountName":
+		return pod.Spec.ServiceAccountName, nil
+	case "status.hostIP":
+		if pod.Status.HostIP != "" {
+			return pod.Status.HostIP, nil
+		}
+		return p.internalIP, nil
+	case "status.podIP", "status.podIPs":
+		return pod.Status.PodIP, nil
+	}
+	return "", fmt.Errorf("unsupported fieldRef %s", fieldPath)
+}
+
+// subscript splits "prefix['key']" into key.
+func subscript(fieldPath, prefix string) (string, bool) {
+	if !strings.HasPrefix(fieldPath, prefix+"['") || !strings.HasSuffix(fieldPath, "']") {
+		return "", false
+	}
+	return fieldPath[len(prefix)+2 : len(fieldPath)-2], true
+}
+
+// formatMap renders m the way the downward API does, one key="value" a line.
+func formatMap(m map[string]string) string {
+	lines := make([]string, 0, len(m))
+	for k, v := range m {
+		lines = append(lines, fmt.Sprintf("%s=%q", k, v))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// containerResourceValue resolves a resourceFieldRef. Unset limits fall back
+// to what the container may use in its group, unset requests to the limits.
+func containerResourceValue(container *v1.Container, limit InstanceSize, ref *v1.ResourceFieldSelector) (string, error) {
+	if ref.ContainerName != "" && ref.ContainerName != container.Name {
+		return "", fmt.Errorf("resourceFieldRef of another container %s is unsupported", ref.ContainerName)
+	}
+	var (
+		name     v1.ResourceName
+		quantity resource.Quantity
+		found    bool
+	)
+	switch {
+	case strings.HasPrefix(ref.Resource, "limits."):
+		name = v1.ResourceName(strings.TrimPrefix(ref.Resource, "limits."))
+		quantity, found = container.Resources.Limits[name]
+	case strings.HasPrefix(ref.Resource, "requests."):
+		name = v1.ResourceName(strings.TrimPrefix(ref.Resource, "requests."))
+		quantity, found = container.Resources.Requests[name]
+		if !found {
+			quantity, found = container.Resources.Limits[name]
+		}
+	default:
+		return "", fmt.Errorf("unsupported resourceFieldRef %s", ref.Resource)
+	}
+	if !found {
+		switch name {
+		case v1.ResourceCPU:
+			quantity = *resource.NewMilliQuantity(int64(limit.Cpu*1000), resource.DecimalSI)
+		case v1.ResourceMemory:
+			quantity = *resource.NewQuantity(int64(limit.Memory*gib), resource.BinarySI)
+		case v1.ResourceEphemeralStorage:
+		default:
+			return "", fmt.Errorf("unsupported resourceFieldRef %s", ref.Resource)
+		}
+	}
+
+	divisor := ref.Divisor
+	if divisor.IsZero() {
+		divisor = resource.MustParse("1")
+	}
+	if name == v1.ResourceCPU {
+		value := int64(math.Ceil(float64(quantity.MilliValue()) / float64(divisor.MilliValue())))
+		return fmt.Sprintf("%d", value), nil
+	}
+	value := int64(math.Ceil(float64(quantity.Value()) / float64(divisor.Value())))
+	return fmt.Sprintf("%d", value), nil
+}
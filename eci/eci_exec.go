@@ -0,0 +1,171 @@
+package eci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/capitalonline/cds-virtual-kubelet/cdsapi"
+	"github.com/gorilla/websocket"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"github.com/virtual-kubelet/virtual-kubelet/node/api"
+	utilexec "k8s.io/utils/exec"
+)
+
+// Exec session channels, every websocket message starts with one of these
+// bytes followed by the payload. An empty stdin message signals stdin EOF.
+const (
+	execStdinChannel byte = iota
+	execStdoutChannel
+	execStderrChannel
+	execStatusChannel
+	execResizeChannel
+)
+
+// RunInContainer executes a command in a container in the pod, copying data
+// between in/out/err and the container's stdin/stdout/stderr.
+func (p *ECIProvider) RunInContainer(ctx context.Context, namespace, podName, containerName string, cmd []string, attach api.AttachIO) error {
+	cg, err := p.getCg(ctx, namespace, podName)
+	if err != nil {
+		return err
+	}
+	containerName, err = pickContainerName(cg, containerName)
+	if err != nil {
+		return err
+	}
+
+	request := ExecContainerCommandRequest{
+		ContainerGroupId: cg.ContainerGroupId,
+		ContainerName:    containerName,
+		Command:          cmd,
+		Tty:              attach.TTY(),
+		Stdin:            attach.Stdin() != nil,
+	}
+	resp := ExecContainerCommandResponse{}
+	cckRequest, _ := p.client.NewCCKRequest(ctx, ExecContainerCommandAction, http.MethodPost, nil, request)
+	response, err := p.client.DoOpenApiRequest(ctx, cckRequest, 0)
+	if err != nil {
+		return err
+	}
+	if _, err = p.client.CdsRespDeal(ctx, response, ExecContainerCommandAction, &resp); err != nil {
+		log.G(ctx).WithField("CDS", "RunInContainer").Error(err)
+		return err
+	}
+	if resp.WebSocketUri == "" {
+		return fmt.Errorf("%s returned no exec session for pod %s", ExecContainerCommandAction, podName)
+	}
+
+	conn, err := cdsapi.DialStream(ctx, resp.WebSocketUri)
+	if err != nil {
+		return err
+	}
+	s := &execSession{conn: conn}
+	return s.run(ctx, attach)
+}
+
+type execSession struct {
+	conn *websocket.Conn
+	// gorilla/websocket allows a single concurrent writer.
+	writeLock sync.Mutex
+}
+
+func (s *execSession) write(channel byte, payload []byte) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	return s.conn.WriteMessage(websocket.BinaryMessage, append([]byte{channel}, payload...))
+}
+
+func (s *execSession) run(ctx context.Context, attach api.AttachIO) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		s.conn.Close()
+	}()
+
+	if in := attach.Stdin(); in != nil {
+		go s.copyStdin(ctx, in)
+	}
+	if resize := attach.Resize(); resize != nil {
+		go s.forwardResize(ctx, resize)
+	}
+
+	for {
+		_, msg, err := s.conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil || websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return ctx.Err()
+			}
+			return fmt.Errorf("exec session closed: %v", err)
+		}
+		if len(msg) == 0 {
+			continue
+		}
+		payload := msg[1:]
+		switch msg[0] {
+		case execStdoutChannel:
+			if out := attach.Stdout(); out != nil {
+				if _, err := out.Write(payload); err != nil {
+					return err
+				}
+			}
+		case execStderrChannel:
+			if out := attach.Stderr(); out != nil {
+				if _, err := out.Write(payload); err != nil {
+					return err
+				}
+			}
+		case execStatusChannel:
+			var status ExecStatus
+			if err := json.Unmarshal(payload, &status); err != nil {
+				return fmt.Errorf("invalid exec status: %v", err)
+			}
+			if status.ExitCode != 0 {
+				return utilexec.CodeExitError{
+					Err:  fmt.Errorf("command terminated with exit code %d: %s", status.ExitCode, status.Message),
+					Code: status.ExitCode,
+				}
+			}
+			return nil
+		}
+	}
+}
+
+func (s *execSession) copyStdin(ctx context.Context, in io.Reader) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if werr := s.write(execStdinChannel, buf[:n]); werr != nil {
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.G(ctx).WithField("CDS", "RunInContainer").Debug(fmt.Sprintf("stdin closed: %v", err))
+			}
+			_ = s.write(execStdinChannel, nil)
+			return
+		}
+	}
+}
+
+func (s *execSession) forwardResize(ctx context.Context, resize <-chan api.TermSize) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case size, ok := <-resize:
+			if !ok {
+				return
+			}
+			b, _ := json.Marshal(ExecTermSize{Width: size.Width, Height: size.Height})
+			if err := s.write(execResizeChannel, b); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package eci
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+// logFollowInterval is how often the log API is polled while following.
+const logFollowInterval = 2 * time.Second
+
+// ContainerLogOpts carries the kubelet log options (v1.PodLogOptions) that the
+// CCK log API can honor.
+type ContainerLogOpts struct {
+	Tail         int
+	SinceSeconds int
+	SinceTime    *time.Time
+	Timestamps   bool
+	Previous     bool
+	LimitBytes   int
+	Follow       bool
+}
+
+// StreamContainerLogs returns the logs of a container in the container group
+// backing the pod. With Follow set the backend is polled incrementally and the
+// stream stays open until ctx is cancelled or the reader is closed.
+func (p *ECIProvider) StreamContainerLogs(ctx context.Context, namespace, podName, containerName string, opts ContainerLogOpts) (io.ReadCloser, error) {
+	cg, err := p.getCg(ctx, namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+	containerName, err = pickContainerName(cg, containerName)
+	if err != nil {
+		return nil, err
+	}
+
+	request := DescribeContainerLogRequest{
+		ContainerGroupId: cg.ContainerGroupId,
+		ContainerName:    containerName,
+		Tail:             opts.Tail,
+		Timestamps:       opts.Timestamps,
+		Previous:         opts.Previous,
+		LimitBytes:       opts.LimitBytes,
+	}
+	since := opts.SinceTime
+	if opts.SinceSeconds > 0 {
+		t := time.Now().Add(-time.Duration(opts.SinceSeconds) * time.Second)
+		since = &t
+	}
+	if since != nil {
+		request.SinceTime = since.UTC().Format(timeFormat)
+	}
+
+	resp, err := p.describeContainerLog(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	var rc io.ReadCloser
+	if opts.Follow {
+		pr, pw := io.Pipe()
+		go p.followContainerLogs(ctx, request, resp, pw)
+		rc = pr
+	} else {
+		rc = io.NopCloser(strings.NewReader(resp.Content))
+	}
+	if opts.LimitBytes > 0 {
+		rc = &limitedReadCloser{Reader: io.LimitReader(rc, int64(opts.LimitBytes)), Closer: rc}
+	}
+	return rc, nil
+}
+
+// followContainerLogs writes resp to w and then keeps polling from the offset
+// the backend returned, until ctx is done or the reader side is closed.
+func (p *ECIProvider) followContainerLogs(ctx context.Context, request DescribeContainerLogRequest, resp *DescribeContainerLogResponse, w *io.PipeWriter) {
+	defer w.Close()
+
+	// tail and since only apply to the first read, later reads resume from the offset.
+	request.Tail, request.SinceTime, request.LimitBytes = 0, "", 0
+
+	ticker := time.NewTicker(logFollowInterval)
+	defer ticker.Stop()
+	for {
+		if resp != nil {
+			if resp.Content != "" {
+				if _, err := io.WriteString(w, resp.Content); err != nil {
+					return
+				}
+			}
+			switch {
+			case resp.NextOffset > 0:
+				request.Offset = resp.NextOffset
+			case resp.Content == "":
+			case request.Offset > 0:
+				// no offset came back, resume past the bytes just written.
+				request.Offset += int64(len(resp.Content))
+			default:
+				// a tail or since read without an offset can't be resumed
+				// without repeating it.
+				log.G(ctx).WithField("CDS", "FollowContainerLogs").Warn("no log offset returned, stop following")
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		next, err := p.describeContainerLog(ctx, request)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.G(ctx).WithField("CDS", "FollowContainerLogs").Warn(err)
+		}
+		resp = next
+	}
+}
+
+func (p *ECIProvider) describeContainerLog(ctx context.Context, request DescribeContainerLogRequest) (*DescribeContainerLogResponse, error) {
+	resp := DescribeContainerLogResponse{}
+	cckRequest, _ := p.client.NewCCKRequest(ctx, DescribeContainerLogAction, http.MethodPost, nil, request)
+	response, err := p.client.DoOpenApiRequest(ctx, cckRequest, 0)
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.client.CdsRespDeal(ctx, response, DescribeContainerLogAction, &resp)
+	if err != nil {
+		log.G(ctx).WithField("CDS", "GetContainerLogs").Error(err)
+		return nil, err
+	}
+	return &resp, nil
+}
+
+type limitedReadCloser struct {
+	io.Reader
+	io.Closer
+}
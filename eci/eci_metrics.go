@@ -0,0 +1,179 @@
+package eci
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	stats "k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// metricsCacheTTL bounds how often a scrape hits the metrics API.
+const metricsCacheTTL = 15 * time.Second
+
+// GetStatsSummary returns the stats summary of the container groups on the
+// node, with the node stats being the sum over all pods.
+func (p *ECIProvider) GetStatsSummary(ctx context.Context) (*stats.Summary, error) {
+	p.metricsSync.Lock()
+	defer p.metricsSync.Unlock()
+
+	if p.lastMetric != nil && time.Since(p.metricsSyncTime) < metricsCacheTTL {
+		return p.lastMetric, nil
+	}
+
+	metrics, err := p.describeContainerGroupMetrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pods := make(map[string]*v1.Pod)
+	for _, pod := range p.resourceManager.GetPods() {
+		pods[pod.Namespace+"-"+pod.Name] = pod
+	}
+
+	now := metav1.Now()
+	summary := &stats.Summary{
+		Node: stats.NodeStats{
+			NodeName:  p.nodeName,
+			StartTime: metav1.NewTime(p.startTime),
+			CPU:       &stats.CPUStats{Time: now, UsageNanoCores: new(uint64), UsageCoreNanoSeconds: new(uint64)},
+			Memory:    &stats.MemoryStats{Time: now, UsageBytes: new(uint64), WorkingSetBytes: new(uint64), RSSBytes: new(uint64)},
+			Network: &stats.NetworkStats{Time: now, InterfaceStats: stats.InterfaceStats{
+				Name: "eth0", RxBytes: new(uint64), RxErrors: new(uint64), TxBytes: new(uint64), TxErrors: new(uint64),
+			}},
+			Fs: &stats.FsStats{Time: now, UsedBytes: new(uint64)},
+		},
+		Pods: make([]stats.PodStats, 0, len(metrics)),
+	}
+
+	for _, m := range metrics {
+		pod, ok := pods[m.Namespace+"-"+m.PodName]
+		if !ok {
+			continue
+		}
+		ps := podStatsFromMetric(pod, &m)
+		addNodeStats(&summary.Node, &ps)
+		summary.Pods = append(summary.Pods, ps)
+	}
+
+	p.lastMetric = summary
+	p.metricsSyncTime = time.Now()
+	return summary, nil
+}
+
+func (p *ECIProvider) describeContainerGroupMetrics(ctx context.Context) ([]ContainerGroupMetric, error) {
+	resp := ContainerGroupMetricsResp{}
+	request := DescribeContainerGroupMetricsRequest{
+		SiteId: SiteId,
+		NodeId: NodeId,
+	}
+	cckRequest, _ := p.client.NewCCKRequest(ctx, DescribeContainerGroupMetricsAction, http.MethodPost, nil, request)
+	response, err := p.client.DoOpenApiRequest(ctx, cckRequest, 0)
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.client.CdsRespDeal(ctx, response, DescribeContainerGroupMetricsAction, &resp)
+	if err != nil {
+		log.G(ctx).WithField("CDS", "GetStatsSummary").Error(err)
+		return nil, err
+	}
+	return resp.Metrics, nil
+}
+
+func podStatsFromMetric(pod *v1.Pod, m *ContainerGroupMetric) stats.PodStats {
+	sampleTime := metav1.Now()
+	if t, err := time.Parse(timeFormat, m.Timestamp); err == nil {
+		sampleTime = metav1.NewTime(t)
+	}
+	startTime := pod.CreationTimestamp
+	if pod.Status.StartTime != nil {
+		startTime = *pod.Status.StartTime
+	}
+
+	ps := stats.PodStats{
+		PodRef: stats.PodReference{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			UID:       string(pod.UID),
+		},
+		StartTime:        startTime,
+		Containers:       make([]stats.ContainerStats, 0, len(m.Containers)),
+		CPU:              &stats.CPUStats{Time: sampleTime, UsageNanoCores: new(uint64), UsageCoreNanoSeconds: new(uint64)},
+		Memory:           &stats.MemoryStats{Time: sampleTime, UsageBytes: new(uint64), WorkingSetBytes: new(uint64), RSSBytes: new(uint64)},
+		EphemeralStorage: &stats.FsStats{Time: sampleTime, UsedBytes: new(uint64)},
+		Network: &stats.NetworkStats{
+			Time: sampleTime,
+			InterfaceStats: stats.InterfaceStats{
+				Name:     m.Network.Interface,
+				RxBytes:  uint64Ptr(m.Network.RxBytes),
+				RxErrors: uint64Ptr(m.Network.RxErrors),
+				TxBytes:  uint64Ptr(m.Network.TxBytes),
+				TxErrors: uint64Ptr(m.Network.TxErrors),
+			},
+		},
+	}
+
+	for _, c := range m.Containers {
+		ps.Containers = append(ps.Containers, stats.ContainerStats{
+			Name:      c.Name,
+			StartTime: startTime,
+			CPU: &stats.CPUStats{
+				Time:                 sampleTime,
+				UsageNanoCores:       uint64Ptr(c.CpuUsageNanoCores),
+				UsageCoreNanoSeconds: uint64Ptr(c.CpuUsageCoreNanoSeconds),
+			},
+			Memory: &stats.MemoryStats{
+				Time:            sampleTime,
+				UsageBytes:      uint64Ptr(c.MemoryUsageBytes),
+				WorkingSetBytes: uint64Ptr(c.MemoryWorkingSetBytes),
+				RSSBytes:        uint64Ptr(c.MemoryRssBytes),
+			},
+			Rootfs: &stats.FsStats{
+				Time:      sampleTime,
+				UsedBytes: uint64Ptr(c.EphemeralStorageUsedBytes),
+			},
+		})
+		*ps.CPU.UsageNanoCores += c.CpuUsageNanoCores
+		*ps.CPU.UsageCoreNanoSeconds += c.CpuUsageCoreNanoSeconds
+		*ps.Memory.UsageBytes += c.MemoryUsageBytes
+		*ps.Memory.WorkingSetBytes += c.MemoryWorkingSetBytes
+		*ps.Memory.RSSBytes += c.MemoryRssBytes
+		*ps.EphemeralStorage.UsedBytes += c.EphemeralStorageUsedBytes
+	}
+
+	for _, v := range m.Volumes {
+		ps.VolumeStats = append(ps.VolumeStats, stats.VolumeStats{
+			Name:    v.Name,
+			FsStats: stats.FsStats{Time: sampleTime, UsedBytes: uint64Ptr(v.UsedBytes)},
+		})
+	}
+	// the disk usage also counts emptyDirs and logs the containers don't.
+	if disk := m.EphemeralStorage; disk.CapacityBytes > 0 {
+		ps.EphemeralStorage.CapacityBytes = uint64Ptr(disk.CapacityBytes)
+		ps.EphemeralStorage.AvailableBytes = uint64Ptr(disk.AvailableBytes)
+		if disk.UsedBytes > 0 {
+			ps.EphemeralStorage.UsedBytes = uint64Ptr(disk.UsedBytes)
+		}
+	}
+	return ps
+}
+
+func addNodeStats(node *stats.NodeStats, ps *stats.PodStats) {
+	*node.CPU.UsageNanoCores += *ps.CPU.UsageNanoCores
+	*node.CPU.UsageCoreNanoSeconds += *ps.CPU.UsageCoreNanoSeconds
+	*node.Memory.UsageBytes += *ps.Memory.UsageBytes
+	*node.Memory.WorkingSetBytes += *ps.Memory.WorkingSetBytes
+	*node.Memory.RSSBytes += *ps.Memory.RSSBytes
+	*node.Network.RxBytes += *ps.Network.RxBytes
+	*node.Network.RxErrors += *ps.Network.RxErrors
+	*node.Network.TxBytes += *ps.Network.TxBytes
+	*node.Network.TxErrors += *ps.Network.TxErrors
+	*node.Fs.UsedBytes += *ps.EphemeralStorage.UsedBytes
+}
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
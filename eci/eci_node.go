@@ -0,0 +1,241 @@
+package eci
+
+import (
+	"context"
+	"fmt"
+	"github.com/capitalonline/cds-virtual-kubelet/cdsapi"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sync"
+	"time"
+)
+
+const (
+	// nodeResourcesInterval is how often the node capacity and allocatable
+	// are compared against what Kubernetes was last told.
+	nodeResourcesInterval = 30 * time.Second
+	// pingFailureThreshold is how many pings in a row must fail before the
+	// node stops being Ready, one success makes it Ready again.
+	pingFailureThreshold = 3
+	// pingInterval is how often the CCK API is probed, pingTimeout bounds
+	// one probe, which is never retried.
+	pingInterval = 10 * time.Second
+	pingTimeout  = 5 * time.Second
+)
+
+// nodeHealth is what the pings tell about the CCK API.
+type nodeHealth int
+
+const (
+	nodeHealthy nodeHealth = iota
+	// nodeAPIFailing: the gateway answers but calls fail, e.g. bad credentials.
+	nodeAPIFailing
+	// nodeUnreachable: the gateway does not answer.
+	nodeUnreachable
+)
+
+// NodeProvider keeps the status of the virtual node in Kubernetes current.
+// Allocatable shrinks as pods are rounded up, Ready and NetworkUnavailable
+// follow the health of the CCK API.
+type NodeProvider struct {
+	p    *ECIProvider
+	node *v1.Node
+
+	mu       sync.Mutex
+	failures int
+	health   nodeHealth
+	lastErr  error
+	changed  chan struct{}
+}
+
+// NewNodeProvider returns the node provider of p, node is the node object
+// the node controller registers.
+func NewNodeProvider(p *ECIProvider, node *v1.Node) *NodeProvider {
+	return &NodeProvider{p: p, node: node.DeepCopy(), changed: make(chan struct{}, 1)}
+}
+
+// Ping only reports the virtual kubelet alive. It runs on the node
+// controller loop, which also renews the lease, so the CCK API is probed by
+// runHealthProbe instead and a failing API shows in the node conditions
+// rather than as an error that would leave the node Unknown with no reason.
+func (n *NodeProvider) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+func (n *NodeProvider) runHealthProbe(ctx context.Context) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		n.probe(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe lists one container group to check the CCK API.
+func (n *NodeProvider) probe(ctx context.Context) {
+	// the probe skips the client limiter, queueing behind our own calls
+	// would read as an unreachable API.
+	probeCtx, cancel := context.WithTimeout(cdsapi.WithoutLimit(cdsapi.WithoutRetry(ctx)), pingTimeout)
+	defer cancel()
+	_, _, err := n.p.describeCgs(probeCtx, DescribeContainerGroupsRequest{
+		SiteId:     SiteId,
+		NodeId:     NodeId,
+		Limit:      1,
+		PageNumber: 1,
+	})
+	if ctx.Err() != nil {
+		return
+	}
+	n.recordPing(ctx, err)
+}
+
+// recordPing updates the health after a ping, a throttled call still shows
+// the API is up.
+func (n *NodeProvider) recordPing(ctx context.Context, err error) {
+	apiErr, answered := cdsapi.AsAPIError(err)
+	if answered && apiErr.Throttled() {
+		err = nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	health := n.health
+	switch {
+	case err == nil:
+		n.failures = 0
+		health = nodeHealthy
+	case n.failures+1 < pingFailureThreshold:
+		n.failures++
+	case answered:
+		n.failures++
+		health = nodeAPIFailing
+	default:
+		n.failures++
+		health = nodeUnreachable
+	}
+	if health == n.health {
+		return
+	}
+	logger := log.G(ctx).WithField("CDS", "NodeStatus")
+	if health == nodeHealthy {
+		logger.Info("CCK API is reachable again, node is Ready")
+	} else {
+		logger.Error(fmt.Sprintf("%v pings failed, node is not Ready: %v", n.failures, err))
+	}
+	n.health = health
+	n.lastErr = err
+	select {
+	case n.changed <- struct{}{}:
+	default:
+	}
+}
+
+// NotifyNodeStatus starts the health probe and passes the node to cb
+// whenever its health, capacity or allocatable changes.
+func (n *NodeProvider) NotifyNodeStatus(ctx context.Context, cb func(*v1.Node)) {
+	go n.runHealthProbe(ctx)
+	go n.runStatusUpdater(ctx, cb)
+}
+
+func (n *NodeProvider) runStatusUpdater(ctx context.Context, cb func(*v1.Node)) {
+	ticker := time.NewTicker(nodeResourcesInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-n.changed:
+		}
+		n.updateStatus(ctx, cb)
+	}
+}
+
+func (n *NodeProvider) updateStatus(ctx context.Context, cb func(*v1.Node)) {
+	changed := false
+	for _, c := range n.healthConditions() {
+		if setNodeCondition(&n.node.Status, c) {
+			changed = true
+		}
+	}
+
+	capacity := n.p.Capacity(ctx)
+	allocatable := n.p.Allocatable(ctx)
+	if !equality.Semantic.DeepEqual(n.node.Status.Capacity, capacity) ||
+		!equality.Semantic.DeepEqual(n.node.Status.Allocatable, allocatable) {
+		log.G(ctx).WithField("CDS", "NodeStatus").Info(fmt.Sprintf("allocatable cpu %s, memory %s, pods %s",
+			allocatable.Cpu(), allocatable.Memory(), allocatable.Pods()))
+		n.node.Status.Capacity = capacity
+		n.node.Status.Allocatable = allocatable
+		changed = true
+	}
+	if changed {
+		cb(n.node.DeepCopy())
+	}
+}
+
+// healthConditions renders the health into the Ready and NetworkUnavailable
+// conditions.
+func (n *NodeProvider) healthConditions() []v1.NodeCondition {
+	n.mu.Lock()
+	health, err := n.health, n.lastErr
+	n.mu.Unlock()
+
+	ready := v1.NodeCondition{
+		Type:    v1.NodeReady,
+		Status:  v1.ConditionTrue,
+		Reason:  "KubeletReady",
+		Message: "kubelet is ready.",
+	}
+	network := v1.NodeCondition{
+		Type:    v1.NodeNetworkUnavailable,
+		Status:  v1.ConditionFalse,
+		Reason:  "RouteCreated",
+		Message: "RouteController created a route",
+	}
+	switch health {
+	case nodeAPIFailing:
+		ready.Status = v1.ConditionFalse
+		ready.Reason = "CCKAPIFailing"
+		ready.Message = fmt.Sprintf("CCK API calls fail: %v", err)
+	case nodeUnreachable:
+		ready.Status = v1.ConditionFalse
+		ready.Reason = "CCKAPIUnreachable"
+		ready.Message = fmt.Sprintf("CCK API is unreachable: %v", err)
+		network.Status = v1.ConditionTrue
+		network.Reason = "CCKAPIUnreachable"
+		network.Message = ready.Message
+	}
+	return []v1.NodeCondition{ready, network}
+}
+
+// setNodeCondition sets c on status, LastTransitionTime only moves when the
+// condition status does. It reports whether anything changed.
+func setNodeCondition(status *v1.NodeStatus, c v1.NodeCondition) bool {
+	now := metav1.Now()
+	for i := range status.Conditions {
+		cur := &status.Conditions[i]
+		if cur.Type != c.Type {
+			continue
+		}
+		if cur.Status == c.Status && cur.Reason == c.Reason && cur.Message == c.Message {
+			return false
+		}
+		if cur.Status != c.Status {
+			cur.LastTransitionTime = now
+		}
+		cur.Status, cur.Reason, cur.Message = c.Status, c.Reason, c.Message
+		cur.LastHeartbeatTime = now
+		return true
+	}
+	c.LastHeartbeatTime = now
+	c.LastTransitionTime = now
+	status.Conditions = append(status.Conditions, c)
+	return true
+}
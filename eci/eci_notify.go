@@ -0,0 +1,121 @@
+package eci
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// podStatusPollInterval is how often the status poller lists the node's container groups.
+	podStatusPollInterval = 5 * time.Second
+	// cgPageSize is the page size used when listing every container group of the node.
+	cgPageSize = 100
+)
+
+// NotifyPods starts the status poller, it lists all container groups of the
+// node in one paged call per interval and passes pods whose status changed
+// since the last snapshot to notifier. The service account token refresher
+// and the orphan reconciler run alongside it.
+func (p *ECIProvider) NotifyPods(ctx context.Context, notifier func(*v1.Pod)) {
+	p.Lock()
+	p.notifier = notifier
+	p.Unlock()
+	go p.runStatusPoller(ctx, notifier)
+	go p.runTokenRefresher(ctx)
+	go p.runReconciler(ctx)
+}
+
+func (p *ECIProvider) runStatusPoller(ctx context.Context, notifier func(*v1.Pod)) {
+	ticker := time.NewTicker(podStatusPollInterval)
+	defer ticker.Stop()
+	for {
+		p.pollPodStatuses(ctx, notifier)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *ECIProvider) pollPodStatuses(ctx context.Context, notifier func(*v1.Pod)) {
+	cgs, err := p.listAllCgs(ctx)
+	if err != nil {
+		log.G(ctx).WithField("CDS", "PollPodStatuses").Warn(err)
+		return
+	}
+
+	snapshot := make(map[string]*v1.Pod, len(cgs))
+	duplicates := make(map[string]bool)
+	for i := range cgs {
+		pod, err := containerGroupToPod(&cgs[i])
+		if err != nil {
+			log.G(ctx).WithField("CDS", "PollPodStatuses").Error(fmt.Sprint("error converting container group to pod ", cgs[i].ContainerGroupId, err))
+			continue
+		}
+		key := pod.Namespace + "-" + pod.Name
+		if _, ok := snapshot[key]; ok {
+			duplicates[key] = true
+		}
+		snapshot[key] = pod
+	}
+	// Same rule as GetPodByCondition: a non-unique group name has no status.
+	for key := range duplicates {
+		log.G(ctx).WithField("CDS", "PollPodStatuses").Warn("get pod is non-uniqueness: ", key)
+		delete(snapshot, key)
+	}
+
+	p.Lock()
+	last := p.podSnapshot
+	p.podSnapshot = snapshot
+	p.Unlock()
+
+	changed := 0
+	for key, pod := range snapshot {
+		if old, ok := last[key]; ok && reflect.DeepEqual(old.Status, pod.Status) {
+			continue
+		}
+		changed++
+		notifier(pod)
+	}
+	for key, pod := range last {
+		if _, ok := snapshot[key]; !ok {
+			changed++
+			notifier(pod)
+		}
+	}
+	log.G(ctx).WithField("CDS", "PollPodStatuses").Debug(fmt.Sprintf("%v container groups, %v changed", len(snapshot), changed))
+}
+
+// snapshotPod returns the pod from the last status poll, if the poller is running.
+func (p *ECIProvider) snapshotPod(namespace, name string) (*v1.Pod, bool) {
+	p.RLock()
+	defer p.RUnlock()
+	pod, ok := p.podSnapshot[namespace+"-"+name]
+	return pod, ok
+}
+
+// listAllCgs lists every container group of the node page by page.
+func (p *ECIProvider) listAllCgs(ctx context.Context) ([]ContainerGroup, error) {
+	var all []ContainerGroup
+	for page := 1; ; page++ {
+		cgs, _, err := p.describeCgs(ctx, DescribeContainerGroupsRequest{
+			SiteId:     SiteId,
+			NodeId:     NodeId,
+			Limit:      cgPageSize,
+			PageNumber: page,
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, cgs.Eci...)
+		if len(cgs.Eci) < cgPageSize || (cgs.Total > 0 && len(all) >= cgs.Total) {
+			return all, nil
+		}
+	}
+}
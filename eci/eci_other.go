@@ -3,38 +3,32 @@ package eci
 import (
 	"context"
 	"fmt"
-	"github.com/capitalonline/cds-virtual-kubelet/cdsapi"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	v1 "k8s.io/api/core/v1"
-	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"net/http"
-	"strings"
 	"time"
 )
 
 func (p *ECIProvider) GetPodByCondition(ctx context.Context, source, namespace, name string) (*v1.Pod, error) {
 	log.G(ctx).WithField("CDS", "GetPodByCondition").Debug(source+": get cds eci: ", name+"-"+namespace)
-	cgs, code, err := p.GetCgs(ctx, namespace, name)
-	if err != nil {
+	cgs, _, err := p.GetCgs(ctx, namespace, name)
+	if err != nil && !errdefs.IsNotFound(err) {
 		return nil, err
 	}
-	if code >= 500 {
-		return nil, err
+	if len(cgs) == 1 {
+		cg := cgs[0]
+		return containerGroupToPod(&cg)
+	} else if len(cgs) > 1 {
+		log.G(ctx).WithField("CDS", "GetPodByCondition").Warn(source+": get pod is non-uniqueness: ", name+" "+namespace)
+		return nil, nil
 	} else {
-		if len(cgs) == 1 {
-			cg := cgs[0]
-			return containerGroupToPod(&cg)
-		} else if len(cgs) > 1 {
-			log.G(ctx).WithField("CDS", "GetPodByCondition").Warn(source+": get pod is non-uniqueness: ", name+" "+namespace)
-			return nil, nil
-		} else {
-			_, ok := p.createdPod.Load(namespace + "-" + name)
-			if ok {
-				log.G(ctx).WithField("CDS", "GetPodByCondition").Error(source+": pod is created, but not query by cck: ", name+" "+namespace)
-			}
-			return nil, nil
+		_, ok := p.createdPod.Load(namespace + "-" + name)
+		if ok {
+			log.G(ctx).WithField("CDS", "GetPodByCondition").Error(source+": pod is created, but not query by cck: ", name+" "+namespace)
 		}
+		return nil, nil
 	}
 }
 
@@ -43,54 +37,86 @@ func (p *ECIProvider) GetCgs(ctx context.Context, namespace, name string) ([]Con
 	if namespace != "" && name != "" {
 		cname = fmt.Sprintf("%s-%s", namespace, name)
 	}
-	cgs := ContainerGroupResp{}
 	request := DescribeContainerGroupsRequest{
 		SiteId:             SiteId,
 		NodeId:             NodeId,
 		Namespace:          namespace,
 		ContainerGroupName: cname,
 	}
-	cckRequest, _ := cdsapi.NewCCKRequest(ctx, DescribeContainerGroupsAction, http.MethodPost, nil, request)
-	response, err := cdsapi.DoOpenApiRequest(ctx, cckRequest, 0)
+	cgs, code, err := p.describeCgs(ctx, request)
+	if err != nil {
+		return nil, code, err
+	}
+	return cgs.Eci, code, nil
+}
+
+func (p *ECIProvider) describeCgs(ctx context.Context, request DescribeContainerGroupsRequest) (*ContainerGroupResp, int, error) {
+	cgs := ContainerGroupResp{}
+	cckRequest, _ := p.client.NewCCKRequest(ctx, DescribeContainerGroupsAction, http.MethodPost, nil, request)
+	response, err := p.client.DoOpenApiRequest(ctx, cckRequest, 0)
 	if err != nil {
 		return nil, 0, err
 	}
-	code, err := cdsapi.CdsRespDeal(ctx, response, DescribeContainerGroupsAction, &cgs)
+	code, err := p.client.CdsRespDeal(ctx, response, DescribeContainerGroupsAction, &cgs)
 	if err != nil {
 		log.G(ctx).WithField("CDS", "GetCgs").Error(err)
 		return nil, code, err
 	}
-	return cgs.Eci, response.StatusCode, nil
+	return &cgs, response.StatusCode, nil
 }
 
-func (p *ECIProvider) getContainers(pod *v1.Pod, init bool) ([]ContainerInfo, float64, float64, error) {
-	var (
-		allCpu float64
-		allMem float64
-	)
+// getCg returns the container group backing the pod namespace/name.
+func (p *ECIProvider) getCg(ctx context.Context, namespace, name string) (*ContainerGroup, error) {
+	cgs, _, err := p.GetCgs(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	cname := fmt.Sprintf("%s-%s", namespace, name)
+	for i := range cgs {
+		if len(cgs) == 1 || cgs[i].ContainerGroupName == cname {
+			return &cgs[i], nil
+		}
+	}
+	return nil, errdefs.NotFoundf("can't find Pod %s", name)
+}
+
+// pickContainerName resolves the container a log or exec request targets, the
+// name may only be omitted when the group runs a single container.
+func pickContainerName(cg *ContainerGroup, name string) (string, error) {
+	names := make([]string, 0, len(cg.Containers))
+	for _, c := range cg.Containers {
+		if c.Name == name {
+			return name, nil
+		}
+		names = append(names, c.Name)
+	}
+	if name == "" {
+		if len(names) == 1 {
+			return names[0], nil
+		}
+		return "", errdefs.InvalidInputf("a container name must be specified for pod %s, choose one of: %v", cg.PodName, names)
+	}
+	return "", errdefs.NotFoundf("container %s is not valid for pod %s", name, cg.PodName)
+}
+
+// getContainers translates the app or init containers of pod, group is the
+// instance size the pod runs on.
+func (p *ECIProvider) getContainers(pod *v1.Pod, init bool, group InstanceSize) ([]ContainerInfo, error) {
 	podContainers := pod.Spec.Containers
 	if init {
 		podContainers = pod.Spec.InitContainers
 	}
 	containers := make([]ContainerInfo, 0, len(podContainers))
-	for _, container := range podContainers {
-		imageList := strings.Split(container.Image, ":")
-		imageName := ""
-		imageVersion := ""
-		if len(imageList) > 1 {
-			imageName = imageList[0]
-			imageVersion = imageList[1]
-		} else {
-			imageName = container.Image
-
-		}
-		if imageVersion == "" {
-			imageVersion = "latest"
+	for i, container := range podContainers {
+		image, err := parseImageRef(container.Image)
+		if err != nil {
+			return nil, fmt.Errorf("container %s of Pod %s: %v", container.Name, pod.Name, err)
 		}
 		c := ContainerInfo{
 			Name:         container.Name,
-			Image:        imageName,
-			ImageVersion: imageVersion,
+			Image:        image.Name,
+			ImageVersion: image.Tag,
+			ImageDigest:  image.Digest,
 			Arg:          container.Args,
 			Command:      container.Command,
 			Ports:        make([]ContainerPort, 0, len(container.Ports)),
@@ -112,47 +138,45 @@ func (p *ECIProvider) getContainers(pod *v1.Pod, init bool) ([]ContainerInfo, fl
 			})
 		}
 
-		c.EnvironmentVars = make([]EnvironmentVar, 0, len(container.Env))
-		for _, e := range container.Env {
-			c.EnvironmentVars = append(c.EnvironmentVars, EnvironmentVar{Key: e.Name, Value: e.Value})
-		}
+		// the backend takes no zero cpu or memory, a container that sets
+		// none gets its share of the group.
+		limit := p.containerLimit(pod, &podContainers[i], init, group)
+		c.Cpu, c.Memory = limit.Cpu, limit.Memory
 
-		cpuRequest := 1.00
-		if init {
-			cpuRequest = 0
+		if c.LivenessProbe, err = getProbe(&podContainers[i], container.LivenessProbe); err != nil {
+			return nil, fmt.Errorf("liveness probe of Pod %s: %v", pod.Name, err)
 		}
-		if _, ok := container.Resources.Limits[v1.ResourceCPU]; ok {
-			cpuRequest = float64(container.Resources.Limits.Cpu().MilliValue()) / 1000.00
-		} else {
-			if _, ok = container.Resources.Requests[v1.ResourceCPU]; ok {
-				cpuRequest = float64(container.Resources.Requests.Cpu().MilliValue()) / 1000.00
-			}
+		if c.ReadinessProbe, err = getProbe(&podContainers[i], container.ReadinessProbe); err != nil {
+			return nil, fmt.Errorf("readiness probe of Pod %s: %v", pod.Name, err)
 		}
-		c.Cpu = cpuRequest
 
-		memoryRequest := 2.0
-		if init {
-			memoryRequest = 0
+		if c.SecurityContext, err = getSecurityContext(pod, &podContainers[i]); err != nil {
+			return nil, err
 		}
-		if _, ok := container.Resources.Limits[v1.ResourceMemory]; ok {
-			memoryRequest = float64(container.Resources.Limits.Memory().Value()) / 1024.0 / 1024.0 / 1024.0
-		} else {
-			if _, ok = container.Resources.Requests[v1.ResourceMemory]; ok {
-				memoryRequest = float64(container.Resources.Requests.Memory().Value()) / 1024.0 / 1024.0 / 1024.0
-			}
+		if c.Lifecycle, err = getLifecycle(&podContainers[i]); err != nil {
+			return nil, fmt.Errorf("lifecycle of Pod %s: %v", pod.Name, err)
+		}
+		c.TerminationMessagePath = container.TerminationMessagePath
+		c.TerminationMessagePolicy = string(container.TerminationMessagePolicy)
+
+		// env is resolved after resources, resourceFieldRef may fall back to them.
+		envs, err := p.getEnvironmentVars(pod, &podContainers[i], limit)
+		if err != nil {
+			return nil, err
 		}
-		c.Memory = memoryRequest
+		c.EnvironmentVars = envs
 
 		c.ImagePullPolicy = string(container.ImagePullPolicy)
 		c.WorkingDir = container.WorkingDir
 		containers = append(containers, c)
-		allCpu += c.Cpu
-		allMem += c.Memory
 	}
-	return containers, allCpu, allMem, nil
+	return containers, nil
 }
 
-func (p *ECIProvider) getImagePullSecrets(pod *v1.Pod) ([]ImageRegistryCredential, error) {
+// getImagePullSecrets gathers the registry credentials of the pod pull
+// secrets followed by those of its service account, the first credential of
+// a registry wins.
+func (p *ECIProvider) getImagePullSecrets(ctx context.Context, pod *v1.Pod) ([]ImageRegistryCredential, error) {
 	ips := make([]ImageRegistryCredential, 0, len(pod.Spec.ImagePullSecrets))
 	for _, ref := range pod.Spec.ImagePullSecrets {
 		secret, err := p.resourceManager.GetSecret(ref.Name, pod.Namespace)
@@ -162,20 +186,62 @@ func (p *ECIProvider) getImagePullSecrets(pod *v1.Pod) ([]ImageRegistryCredentia
 		if secret == nil {
 			return nil, fmt.Errorf("error getting image pull secret")
 		}
-		switch secret.Type {
-		case v1.SecretTypeDockercfg:
-			ips, err = readDockerCfgSecret(secret, ips)
-		case v1.SecretTypeDockerConfigJson:
-			ips, err = readDockerConfigJSONSecret(secret, ips)
-		default:
-			return nil, fmt.Errorf("image pull secret type is not one of kubernetes.io/dockercfg or kubernetes.io/dockerconfigjson")
+		ips, err = readPullSecret(secret, ips)
+		if err != nil {
+			return ips, err
 		}
+	}
 
+	// the service account secrets are a best effort, like the kubelet does.
+	for _, ref := range p.serviceAccountPullSecrets(ctx, pod) {
+		secret, err := p.resourceManager.GetSecret(ref.Name, pod.Namespace)
+		if err != nil || secret == nil {
+			log.G(ctx).WithField("CDS", "GetImagePullSecrets").Warn(fmt.Sprintf("skip pull secret %s of service account %s: %v", ref.Name, serviceAccountName(pod), err))
+			continue
+		}
+		// a broken secret is skipped whole, keeping what was gathered so far.
+		read, err := readPullSecret(secret, ips)
 		if err != nil {
-			return ips, err
+			log.G(ctx).WithField("CDS", "GetImagePullSecrets").Warn(fmt.Sprintf("skip pull secret %s of service account %s: %v", ref.Name, serviceAccountName(pod), err))
+			continue
 		}
+		ips = read
 	}
-	return ips, nil
+	return dedupeCredentials(ips), nil
+}
+
+func readPullSecret(secret *v1.Secret, ips []ImageRegistryCredential) ([]ImageRegistryCredential, error) {
+	switch secret.Type {
+	case v1.SecretTypeDockercfg:
+		return readDockerCfgSecret(secret, ips)
+	case v1.SecretTypeDockerConfigJson:
+		return readDockerConfigJSONSecret(secret, ips)
+	}
+	return ips, fmt.Errorf("image pull secret type is not one of kubernetes.io/dockercfg or kubernetes.io/dockerconfigjson")
+}
+
+// serviceAccountPullSecrets returns the pull secrets of the pod service
+// account that the pod does not list itself.
+func (p *ECIProvider) serviceAccountPullSecrets(ctx context.Context, pod *v1.Pod) []v1.LocalObjectReference {
+	if p.kubeClient == nil {
+		return nil
+	}
+	sa, err := p.kubeClient.CoreV1().ServiceAccounts(pod.Namespace).Get(serviceAccountName(pod), metav1.GetOptions{})
+	if err != nil {
+		log.G(ctx).WithField("CDS", "GetImagePullSecrets").Warn(fmt.Sprintf("get service account %s of Pod %s: %v", serviceAccountName(pod), pod.Name, err))
+		return nil
+	}
+	listed := make(map[string]bool, len(pod.Spec.ImagePullSecrets))
+	for _, ref := range pod.Spec.ImagePullSecrets {
+		listed[ref.Name] = true
+	}
+	refs := make([]v1.LocalObjectReference, 0, len(sa.ImagePullSecrets))
+	for _, ref := range sa.ImagePullSecrets {
+		if !listed[ref.Name] {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
 }
 
 func (p *ECIProvider) getVolumes(pod *v1.Pod) ([]Volume, error) {
@@ -183,10 +249,16 @@ func (p *ECIProvider) getVolumes(pod *v1.Pod) ([]Volume, error) {
 	for _, v := range pod.Spec.Volumes {
 		// Handle the case for the EmptyDir.
 		if v.EmptyDir != nil {
+			sizeLimit, err := emptyDirSizeLimit(v.EmptyDir)
+			if err != nil {
+				return nil, fmt.Errorf("volume %s of Pod %s: %v", v.Name, pod.Name, err)
+			}
 			volumes = append(volumes, Volume{
-				Type:                 VOL_TYPE_EMPTYDIR,
-				Name:                 v.Name,
-				EmptyDirVolumeEnable: true,
+				Type:                    VOL_TYPE_EMPTYDIR,
+				Name:                    v.Name,
+				EmptyDirVolumeEnable:    true,
+				EmptyDirVolumeMedium:    string(v.EmptyDir.Medium),
+				EmptyDirVolumeSizeLimit: sizeLimit,
 			})
 			continue
 		}
@@ -205,56 +277,47 @@ func (p *ECIProvider) getVolumes(pod *v1.Pod) ([]Volume, error) {
 
 		// Handle the case for ConfigMap volume.
 		if v.ConfigMap != nil {
-			ConfigFileToPaths := make([]ConfigFileToPath, 0)
-			configMap, err := p.resourceManager.GetConfigMap(v.ConfigMap.Name, pod.Namespace)
-			if v.ConfigMap.Optional != nil && !*v.ConfigMap.Optional && k8serr.IsNotFound(err) {
-				return nil, fmt.Errorf("ConfigMap %s is required by Pod %s and does not exist", v.ConfigMap.Name, pod.Name)
+			volume, err := p.configMapVolume(pod, v.Name, v.ConfigMap)
+			if err != nil {
+				return nil, err
 			}
-			if configMap == nil {
-				continue
-			}
-
-			for k, content := range configMap.Data {
-				//var b bytes.Buffer
-				//enc := base64.NewEncoder(base64.StdEncoding, &b)
-				//enc.Write([]byte(v))
+			volumes = append(volumes, volume)
+			continue
+		}
 
-				ConfigFileToPaths = append(ConfigFileToPaths, ConfigFileToPath{Path: k, Content: content})
+		if v.Secret != nil {
+			volume, err := p.secretVolume(pod, v.Name, v.Secret)
+			if err != nil {
+				return nil, err
 			}
+			volumes = append(volumes, volume)
+			continue
+		}
 
-			if len(ConfigFileToPaths) != 0 {
-				volumes = append(volumes, Volume{
-					Type:              VOL_TYPE_CONFIGFILEVOLUME,
-					Name:              v.Name,
-					ConfigFileToPaths: ConfigFileToPaths,
-				})
+		if v.DownwardAPI != nil {
+			volume, err := p.downwardAPIVolume(pod, v.Name, v.DownwardAPI)
+			if err != nil {
+				return nil, err
 			}
+			volumes = append(volumes, volume)
 			continue
 		}
 
-		if v.Secret != nil {
-			ConfigFileToPaths := make([]ConfigFileToPath, 0)
-			secret, err := p.resourceManager.GetSecret(v.Secret.SecretName, pod.Namespace)
-			if v.Secret.Optional != nil && !*v.Secret.Optional && k8serr.IsNotFound(err) {
-				return nil, fmt.Errorf("Secret %s is required by Pod %s and does not exist", v.Secret.SecretName, pod.Name)
-			}
-			if secret == nil {
-				continue
-			}
-			for k, content := range secret.Data {
-				//var b bytes.Buffer
-				//enc := base64.NewEncoder(base64.StdEncoding, &b)
-				//enc.Write(v)
-				ConfigFileToPaths = append(ConfigFileToPaths, ConfigFileToPath{Path: k, Content: string(content)})
+		if v.Projected != nil {
+			volume, err := p.projectedVolume(pod, v.Name, v.Projected)
+			if err != nil {
+				return nil, err
 			}
+			volumes = append(volumes, volume)
+			continue
+		}
 
-			if len(ConfigFileToPaths) != 0 {
-				volumes = append(volumes, Volume{
-					Type:              VOL_TYPE_CONFIGFILEVOLUME,
-					Name:              v.Name,
-					ConfigFileToPaths: ConfigFileToPaths,
-				})
+		if v.PersistentVolumeClaim != nil {
+			volume, err := p.persistentVolume(pod, v.Name, v.PersistentVolumeClaim)
+			if err != nil {
+				return nil, err
 			}
+			volumes = append(volumes, volume)
 			continue
 		}
 
@@ -0,0 +1,87 @@
+package eci
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// getProbe translates a liveness or readiness probe of container.
+func getProbe(container *v1.Container, probe *v1.Probe) (*Probe, error) {
+	if probe == nil {
+		return nil, nil
+	}
+	handler, err := getHandler(container, probe.Handler)
+	if err != nil {
+		return nil, err
+	}
+	return &Probe{
+		Handler:             handler,
+		InitialDelaySeconds: probe.InitialDelaySeconds,
+		TimeoutSeconds:      probe.TimeoutSeconds,
+		PeriodSeconds:       probe.PeriodSeconds,
+		SuccessThreshold:    probe.SuccessThreshold,
+		FailureThreshold:    probe.FailureThreshold,
+	}, nil
+}
+
+func getHandler(container *v1.Container, h v1.Handler) (Handler, error) {
+	switch {
+	case h.Exec != nil:
+		return Handler{Exec: &ExecAction{Command: h.Exec.Command}}, nil
+	case h.HTTPGet != nil:
+		port, err := containerPort(container, h.HTTPGet.Port)
+		if err != nil {
+			return Handler{}, err
+		}
+		scheme := string(h.HTTPGet.Scheme)
+		if scheme == "" {
+			scheme = string(v1.URISchemeHTTP)
+		}
+		action := &HttpGetAction{
+			Path:   h.HTTPGet.Path,
+			Port:   port,
+			Host:   h.HTTPGet.Host,
+			Scheme: scheme,
+		}
+		for _, header := range h.HTTPGet.HTTPHeaders {
+			action.HttpHeaders = append(action.HttpHeaders, HttpHeader{Name: header.Name, Value: header.Value})
+		}
+		return Handler{HttpGet: action}, nil
+	case h.TCPSocket != nil:
+		port, err := containerPort(container, h.TCPSocket.Port)
+		if err != nil {
+			return Handler{}, err
+		}
+		return Handler{TcpSocket: &TcpSocketAction{Port: port, Host: h.TCPSocket.Host}}, nil
+	}
+	return Handler{}, fmt.Errorf("container %s has a handler without exec, httpGet or tcpSocket", container.Name)
+}
+
+// containerPort resolves a port number or the name of one of container's ports.
+func containerPort(container *v1.Container, port intstr.IntOrString) (int, error) {
+	if port.Type == intstr.Int {
+		if port.IntVal <= 0 || port.IntVal > 65535 {
+			return 0, fmt.Errorf("invalid port %d of container %s", port.IntVal, container.Name)
+		}
+		return int(port.IntVal), nil
+	}
+	for _, p := range container.Ports {
+		if p.Name == port.StrVal {
+			return int(p.ContainerPort), nil
+		}
+	}
+	return 0, fmt.Errorf("container %s has no port named %s", container.Name, port.StrVal)
+}
+
+// containerReady reports whether a container of a group passes readiness,
+// containers without a readiness probe are ready once running.
+func containerReady(c *ContainerInfo) bool {
+	if c.CurrentState == nil || eciStateToPodPhase(c.CurrentState.State) != v1.PodRunning {
+		return false
+	}
+	if c.ReadinessProbe == nil {
+		return true
+	}
+	return c.Ready != nil && *c.Ready
+}
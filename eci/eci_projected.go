@@ -0,0 +1,145 @@
+package eci
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// downwardAPIVolume renders the pod fields of a downwardAPI volume into files.
+func (p *ECIProvider) downwardAPIVolume(pod *v1.Pod, name string, source *v1.DownwardAPIVolumeSource) (Volume, error) {
+	volume := Volume{
+		Type:        VOL_TYPE_CONFIGFILEVOLUME,
+		Name:        name,
+		DefaultMode: fileMode(source.DefaultMode, v1.DownwardAPIVolumeSourceDefaultMode),
+	}
+	files, err := p.downwardAPIFiles(pod, source.Items, volume.DefaultMode)
+	if err != nil {
+		return volume, fmt.Errorf("downwardAPI volume %s of Pod %s: %v", name, pod.Name, err)
+	}
+	volume.ConfigFileToPaths = files
+	return volume, nil
+}
+
+// projectedVolume renders every source of a projected volume into one
+// config file volume. Service account tokens are requested from the
+// TokenRequest API and refreshed by the token refresher.
+func (p *ECIProvider) projectedVolume(pod *v1.Pod, name string, source *v1.ProjectedVolumeSource) (Volume, error) {
+	volume := Volume{
+		Type:        VOL_TYPE_CONFIGFILEVOLUME,
+		Name:        name,
+		DefaultMode: fileMode(source.DefaultMode, v1.ProjectedVolumeSourceDefaultMode),
+	}
+	volume.ConfigFileToPaths = make([]ConfigFileToPath, 0)
+	for _, s := range source.Sources {
+		var (
+			files []ConfigFileToPath
+			err   error
+		)
+		switch {
+		case s.Secret != nil:
+			files, err = p.projectedSecret(pod, s.Secret, volume.DefaultMode)
+		case s.ConfigMap != nil:
+			files, err = p.projectedConfigMap(pod, s.ConfigMap, volume.DefaultMode)
+		case s.DownwardAPI != nil:
+			files, err = p.downwardAPIFiles(pod, s.DownwardAPI.Items, volume.DefaultMode)
+		case s.ServiceAccountToken != nil:
+			var token string
+			token, err = p.tokens.getToken(pod, s.ServiceAccountToken)
+			if err == nil {
+				err = validateFilePath(s.ServiceAccountToken.Path)
+			}
+			files = []ConfigFileToPath{configFile(s.ServiceAccountToken.Path, []byte(token), volume.DefaultMode)}
+		default:
+			err = fmt.Errorf("unsupported projection")
+		}
+		if err != nil {
+			return volume, fmt.Errorf("projected volume %s of Pod %s: %v", name, pod.Name, err)
+		}
+		volume.ConfigFileToPaths = append(volume.ConfigFileToPaths, files...)
+	}
+	return volume, nil
+}
+
+func (p *ECIProvider) projectedSecret(pod *v1.Pod, source *v1.SecretProjection, defaultMode int32) ([]ConfigFileToPath, error) {
+	secret, err := p.resourceManager.GetSecret(source.Name, pod.Namespace)
+	if err != nil {
+		if k8serr.IsNotFound(err) && isOptional(source.Optional) {
+			return nil, nil
+		}
+		return nil, refError("Secret", source.Name, pod, err)
+	}
+	files, err := projectKeys(secret.Data, source.Items, defaultMode, isOptional(source.Optional))
+	if err != nil {
+		return nil, fmt.Errorf("Secret %s: %v", source.Name, err)
+	}
+	return files, nil
+}
+
+func (p *ECIProvider) projectedConfigMap(pod *v1.Pod, source *v1.ConfigMapProjection, defaultMode int32) ([]ConfigFileToPath, error) {
+	configMap, err := p.resourceManager.GetConfigMap(source.Name, pod.Namespace)
+	if err != nil {
+		if k8serr.IsNotFound(err) && isOptional(source.Optional) {
+			return nil, nil
+		}
+		return nil, refError("ConfigMap", source.Name, pod, err)
+	}
+	data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+	for k, v := range configMap.Data {
+		data[k] = []byte(v)
+	}
+	for k, v := range configMap.BinaryData {
+		data[k] = v
+	}
+	files, err := projectKeys(data, source.Items, defaultMode, isOptional(source.Optional))
+	if err != nil {
+		return nil, fmt.Errorf("ConfigMap %s: %v", source.Name, err)
+	}
+	return files, nil
+}
+
+// downwardAPIFiles renders pod fields and container resources into files.
+func (p *ECIProvider) downwardAPIFiles(pod *v1.Pod, items []v1.DownwardAPIVolumeFile, defaultMode int32) ([]ConfigFileToPath, error) {
+	files := make([]ConfigFileToPath, 0, len(items))
+	for _, item := range items {
+		if err := validateFilePath(item.Path); err != nil {
+			return nil, err
+		}
+		var (
+			value string
+			err   error
+		)
+		switch {
+		case item.FieldRef != nil:
+			value, err = p.podFieldValue(pod, item.FieldRef.FieldPath)
+		case item.ResourceFieldRef != nil:
+			value, err = p.podResourceValue(pod, item.ResourceFieldRef)
+		default:
+			err = fmt.Errorf("file %s has neither fieldRef nor resourceFieldRef", item.Path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, configFile(item.Path, []byte(value), fileMode(item.Mode, defaultMode)))
+	}
+	return files, nil
+}
+
+// podResourceValue resolves a resourceFieldRef of a volume, which has to name
+// the container.
+func (p *ECIProvider) podResourceValue(pod *v1.Pod, ref *v1.ResourceFieldSelector) (string, error) {
+	group, err := p.podInstanceSize(pod)
+	if err != nil {
+		return "", err
+	}
+	for n, containers := range [][]v1.Container{pod.Spec.Containers, pod.Spec.InitContainers} {
+		for i := range containers {
+			if containers[i].Name != ref.ContainerName {
+				continue
+			}
+			limit := p.containerLimit(pod, &containers[i], n == 1, group)
+			return containerResourceValue(&containers[i], limit, ref)
+		}
+	}
+	return "", fmt.Errorf("resourceFieldRef container %q does not exist", ref.ContainerName)
+}
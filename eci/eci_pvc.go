@@ -0,0 +1,64 @@
+package eci
+
+import (
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Kinds of the CDS storage a persistent volume is backed by.
+const (
+	PV_KIND_DISK = "disk"
+	PV_KIND_NAS  = "nas"
+	PV_KIND_OSS  = "oss"
+)
+
+// cdsCSIDrivers maps the CSI drivers of the CDS storage products to the kind
+// of volume the container group mounts.
+var cdsCSIDrivers = map[string]string{
+	"disk.csi.cds.net": PV_KIND_DISK,
+	"nas.csi.cds.net":  PV_KIND_NAS,
+	"oss.csi.cds.net":  PV_KIND_OSS,
+}
+
+// persistentVolume resolves a claim to its bound persistent volume. Only
+// volumes of the CDS CSI drivers can be attached to a container group.
+func (p *ECIProvider) persistentVolume(pod *v1.Pod, name string, source *v1.PersistentVolumeClaimVolumeSource) (Volume, error) {
+	volume := Volume{Type: VOL_TYPE_PERSISTENT, Name: name}
+	if p.kubeClient == nil {
+		return volume, fmt.Errorf("no kubernetes client to resolve PersistentVolumeClaim %s", source.ClaimName)
+	}
+	pvc, err := p.kubeClient.CoreV1().PersistentVolumeClaims(pod.Namespace).Get(source.ClaimName, metav1.GetOptions{})
+	if err != nil {
+		return volume, refError("PersistentVolumeClaim", source.ClaimName, pod, err)
+	}
+	if pvc.Status.Phase != v1.ClaimBound || pvc.Spec.VolumeName == "" {
+		return volume, fmt.Errorf("PersistentVolumeClaim %s of Pod %s is not bound", source.ClaimName, pod.Name)
+	}
+	pv, err := p.kubeClient.CoreV1().PersistentVolumes().Get(pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return volume, fmt.Errorf("get PersistentVolume %s of claim %s: %v", pvc.Spec.VolumeName, source.ClaimName, err)
+	}
+	if pv.Spec.ClaimRef != nil && pv.Spec.ClaimRef.UID != "" && pv.Spec.ClaimRef.UID != pvc.UID {
+		return volume, fmt.Errorf("PersistentVolume %s is bound to another claim than %s", pv.Name, source.ClaimName)
+	}
+	csi := pv.Spec.CSI
+	if csi == nil {
+		return volume, fmt.Errorf("PersistentVolume %s of claim %s is not provisioned by a CDS CSI driver", pv.Name, source.ClaimName)
+	}
+	kind, ok := cdsCSIDrivers[csi.Driver]
+	if !ok {
+		return volume, fmt.Errorf("PersistentVolume %s of claim %s uses unsupported CSI driver %s", pv.Name, source.ClaimName, csi.Driver)
+	}
+	if csi.VolumeHandle == "" {
+		return volume, fmt.Errorf("PersistentVolume %s of claim %s has no volume handle", pv.Name, source.ClaimName)
+	}
+	volume.PersistentVolume = &PersistentVolume{
+		Kind:         kind,
+		VolumeHandle: csi.VolumeHandle,
+		FsType:       csi.FSType,
+		ReadOnly:     source.ReadOnly || csi.ReadOnly,
+		Attributes:   csi.VolumeAttributes,
+	}
+	return volume, nil
+}
@@ -0,0 +1,160 @@
+package eci
+
+import (
+	"context"
+	"fmt"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"time"
+)
+
+const (
+	DefaultReconcileInterval = 5 * time.Minute
+	DefaultOrphanGracePeriod = 10 * time.Minute
+)
+
+// ReconcileOptions configures the orphan container group garbage collection.
+type ReconcileOptions struct {
+	// Interval between two reconciles, the first one runs at startup.
+	Interval time.Duration
+	// GracePeriod is how long a container group must be without a pod
+	// before it is deleted.
+	GracePeriod time.Duration
+	// DryRun reports orphans without deleting them.
+	DryRun bool
+}
+
+// WithReconcileOptions configures the reconciler.
+func WithReconcileOptions(opts ReconcileOptions) ProviderOption {
+	return func(p *ECIProvider) {
+		p.reconcile = opts
+	}
+}
+
+// WithPodsSynced sets the readiness of the pod informer the reconciler waits
+// for, without it no orphan would be safe to delete and the reconciler is off.
+func WithPodsSynced(synced cache.InformerSynced) ProviderOption {
+	return func(p *ECIProvider) {
+		p.podsSynced = synced
+	}
+}
+
+// WithEventRecorder sets the recorder of the provider events. Reconciler
+// events are attached to the node as orphans have no pod.
+func WithEventRecorder(recorder record.EventRecorder) ProviderOption {
+	return func(p *ECIProvider) {
+		p.recorder = recorder
+	}
+}
+
+func (p *ECIProvider) runReconciler(ctx context.Context) {
+	if p.reconcile.Interval <= 0 || p.podsSynced == nil {
+		return
+	}
+	if !cache.WaitForCacheSync(ctx.Done(), p.podsSynced) {
+		return
+	}
+	ticker := time.NewTicker(p.reconcile.Interval)
+	defer ticker.Stop()
+	for {
+		p.reconcileContainerGroups(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcileContainerGroups matches the container groups of the node against
+// the pods in the informer cache. Matched groups restore what CreatePod
+// remembered before a restart, unmatched ones are deleted once they have been
+// orphaned for the grace period.
+func (p *ECIProvider) reconcileContainerGroups(ctx context.Context) {
+	cgs, err := p.listAllCgs(ctx)
+	if err != nil {
+		log.G(ctx).WithField("CDS", "Reconcile").Warn(err)
+		return
+	}
+	pods := make(map[string]*v1.Pod)
+	for _, pod := range p.resourceManager.GetPods() {
+		pods[pod.Namespace+"-"+pod.Name] = pod
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool, len(cgs))
+	for i := range cgs {
+		cg := &cgs[i]
+		key := cg.Namespace + "-" + cg.PodName
+		seen[cg.ContainerGroupId] = true
+		if pod, ok := pods[key]; ok {
+			delete(p.orphans, cg.ContainerGroupId)
+			delete(p.reportedOrphans, cg.ContainerGroupId)
+			if _, ok := p.createdPod.Load(key); !ok {
+				p.createdPod.Store(key, "running")
+			}
+			if _, ok := p.podSizes.Load(key); !ok {
+				p.podSizes.Store(key, podSize{size: InstanceSize{Cpu: cg.Cpu, Memory: cg.Memory}, scheduled: scheduledRequest(pod)})
+			}
+			continue
+		}
+
+		// a group created just now may be ahead of the informer cache.
+		if t, err := time.Parse(podTagTimeFormat, cg.CreationTime); err == nil && now.Sub(t) < p.reconcile.GracePeriod {
+			continue
+		}
+		// the grace period runs from when the pod is first seen missing, a
+		// long running group must not go the moment its pod leaves the cache.
+		since, ok := p.orphans[cg.ContainerGroupId]
+		if !ok {
+			since = now
+			p.orphans[cg.ContainerGroupId] = since
+		}
+		if now.Sub(since) < p.reconcile.GracePeriod {
+			continue
+		}
+		p.collectOrphan(ctx, cg, now.Sub(since))
+	}
+	for id := range p.orphans {
+		if !seen[id] {
+			delete(p.orphans, id)
+			delete(p.reportedOrphans, id)
+		}
+	}
+}
+
+func (p *ECIProvider) collectOrphan(ctx context.Context, cg *ContainerGroup, orphaned time.Duration) {
+	logger := log.G(ctx).WithField("CDS", "Reconcile")
+	name := fmt.Sprintf("%s (%s-%s)", cg.ContainerGroupId, cg.Namespace, cg.PodName)
+	if p.reconcile.DryRun {
+		if _, reported := p.reportedOrphans[cg.ContainerGroupId]; !reported {
+			logger.Warn(fmt.Sprintf("dry run: would delete container group %s without pod for %v", name, orphaned.Round(time.Second)))
+			p.event(v1.EventTypeWarning, "OrphanContainerGroup", "container group %s has no pod, dry run keeps it", name)
+			recordOrphan(ctx, "dry_run")
+			p.reportedOrphans[cg.ContainerGroupId] = true
+		}
+		return
+	}
+
+	if err := p.deleteContainerGroup(ctx, cg.ContainerGroupId, nil); err != nil {
+		logger.Error(fmt.Sprintf("delete orphan container group %s: %v", name, err))
+		p.event(v1.EventTypeWarning, "OrphanContainerGroupDeleteFailed", "delete container group %s without pod: %v", name, err)
+		recordOrphan(ctx, "failed")
+		return
+	}
+	logger.Info(fmt.Sprintf("deleted container group %s without pod for %v", name, orphaned.Round(time.Second)))
+	p.event(v1.EventTypeNormal, "OrphanContainerGroupDeleted", "deleted container group %s without pod", name)
+	recordOrphan(ctx, "deleted")
+	delete(p.orphans, cg.ContainerGroupId)
+}
+
+func (p *ECIProvider) event(eventType, reason, messageFmt string, args ...interface{}) {
+	if p.recorder == nil {
+		return
+	}
+	node := &v1.ObjectReference{Kind: "Node", Name: p.nodeName, UID: types.UID(p.nodeName)}
+	p.recorder.Eventf(node, eventType, reason, messageFmt, args...)
+}
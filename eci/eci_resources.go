@@ -0,0 +1,263 @@
+package eci
+
+import (
+	"context"
+	"fmt"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// instanceSizeAnnotation shows the instance size a pod was created with.
+const instanceSizeAnnotation = "eci-instance-size"
+
+const gib = 1024 * 1024 * 1024
+
+// InstanceSize is a container group size, cpu in cores and memory in GiB.
+type InstanceSize struct {
+	Cpu    float64
+	Memory float64
+}
+
+var instanceSizeFormat = regexp.MustCompile(`^(?i)([0-9]+(?:\.[0-9]+)?)C([0-9]+(?:\.[0-9]+)?)G$`)
+
+// ParseInstanceSize parses sizes written like 2C4G or 0.5C1G.
+func ParseInstanceSize(s string) (InstanceSize, error) {
+	m := instanceSizeFormat.FindStringSubmatch(s)
+	if m == nil {
+		return InstanceSize{}, fmt.Errorf("invalid instance size %q, expected e.g. 2C4G", s)
+	}
+	cpu, _ := strconv.ParseFloat(m[1], 64)
+	mem, _ := strconv.ParseFloat(m[2], 64)
+	return InstanceSize{Cpu: cpu, Memory: mem}, nil
+}
+
+func (s InstanceSize) String() string {
+	return fmt.Sprintf("%gC%gG", s.Cpu, s.Memory)
+}
+
+func (s InstanceSize) IsZero() bool {
+	return s.Cpu == 0 && s.Memory == 0
+}
+
+func (s InstanceSize) fits(request InstanceSize) bool {
+	return s.Cpu >= request.Cpu && s.Memory >= request.Memory
+}
+
+// DefaultInstanceSize is used for pods that request no cpu or memory.
+var DefaultInstanceSize = InstanceSize{Cpu: 1, Memory: 2}
+
+// ProviderOption configures an ECIProvider.
+type ProviderOption func(*ECIProvider)
+
+// WithInstanceSizes sets the sizes pod requests are rounded up to, the
+// sizes the backend sells. Without any a group gets exactly the request.
+func WithInstanceSizes(sizes []InstanceSize) ProviderOption {
+	return func(p *ECIProvider) {
+		p.instanceSizes = append([]InstanceSize(nil), sizes...)
+	}
+}
+
+// WithDefaultInstanceSize sets the size of pods that request no cpu or
+// memory, namespaces may override it.
+func WithDefaultInstanceSize(size InstanceSize, namespaces map[string]InstanceSize) ProviderOption {
+	return func(p *ECIProvider) {
+		p.defaultSize = size
+		p.namespaceSizes = namespaces
+	}
+}
+
+// WithPodOverhead sets what the group runtime takes on top of the containers.
+func WithPodOverhead(overhead InstanceSize) ProviderOption {
+	return func(p *ECIProvider) {
+		p.overhead = overhead
+	}
+}
+
+// podSize is what a created pod costs and what the scheduler accounts for it.
+type podSize struct {
+	size      InstanceSize
+	scheduled InstanceSize
+}
+
+// containerCpuMemory returns the cpu cores and memory GiB container runs
+// with, limits take precedence over requests. Unset values are 0, the
+// container then gets its share of the group, see containerLimit.
+func containerCpuMemory(container *v1.Container) InstanceSize {
+	var s InstanceSize
+	if q, ok := container.Resources.Limits[v1.ResourceCPU]; ok {
+		s.Cpu = float64(q.MilliValue()) / 1000.00
+	} else if q, ok := container.Resources.Requests[v1.ResourceCPU]; ok {
+		s.Cpu = float64(q.MilliValue()) / 1000.00
+	}
+	if q, ok := container.Resources.Limits[v1.ResourceMemory]; ok {
+		s.Memory = float64(q.Value()) / gib
+	} else if q, ok := container.Resources.Requests[v1.ResourceMemory]; ok {
+		s.Memory = float64(q.Value()) / gib
+	}
+	return s
+}
+
+// effectiveRequest applies the Kubernetes rule for init containers, which
+// run one at a time before the app containers: max(sum(containers),
+// max(initContainers)) in every dimension.
+func effectiveRequest(pod *v1.Pod, size func(*v1.Container) InstanceSize) InstanceSize {
+	var sum, initMax InstanceSize
+	for i := range pod.Spec.Containers {
+		s := size(&pod.Spec.Containers[i])
+		sum.Cpu += s.Cpu
+		sum.Memory += s.Memory
+	}
+	for i := range pod.Spec.InitContainers {
+		s := size(&pod.Spec.InitContainers[i])
+		if s.Cpu > initMax.Cpu {
+			initMax.Cpu = s.Cpu
+		}
+		if s.Memory > initMax.Memory {
+			initMax.Memory = s.Memory
+		}
+	}
+	if initMax.Cpu > sum.Cpu {
+		sum.Cpu = initMax.Cpu
+	}
+	if initMax.Memory > sum.Memory {
+		sum.Memory = initMax.Memory
+	}
+	return sum
+}
+
+// scheduledRequest is what the scheduler reserves on the node for pod.
+func scheduledRequest(pod *v1.Pod) InstanceSize {
+	return effectiveRequest(pod, func(c *v1.Container) InstanceSize {
+		return InstanceSize{
+			Cpu:    float64(c.Resources.Requests.Cpu().MilliValue()) / 1000.00,
+			Memory: float64(c.Resources.Requests.Memory().Value()) / gib,
+		}
+	})
+}
+
+// podInstanceSize returns the smallest instance size that fits the effective
+// request of pod plus the overhead. Dimensions the pod requests nothing of
+// come from the default size of its namespace.
+func (p *ECIProvider) podInstanceSize(pod *v1.Pod) (InstanceSize, error) {
+	request := effectiveRequest(pod, containerCpuMemory)
+	defaultSize := p.defaultSize
+	if size, ok := p.namespaceSizes[pod.Namespace]; ok {
+		defaultSize = size
+	}
+	if request.Cpu == 0 {
+		request.Cpu = defaultSize.Cpu
+	}
+	if request.Memory == 0 {
+		request.Memory = defaultSize.Memory
+	}
+	request.Cpu += p.overhead.Cpu
+	request.Memory += p.overhead.Memory
+
+	// containers that set nothing next to ones that do share what is left
+	// of the group, which must then not be filled exactly.
+	roomCpu, roomMemory := needsRoom(pod)
+	sizes := p.instanceSizes
+	if len(sizes) == 0 {
+		if roomCpu {
+			request.Cpu += defaultSize.Cpu
+		}
+		if roomMemory {
+			request.Memory += defaultSize.Memory
+		}
+		return request, nil
+	}
+	for _, size := range sizes {
+		if size.fits(request) && (!roomCpu || size.Cpu > request.Cpu) && (!roomMemory || size.Memory > request.Memory) {
+			return size, nil
+		}
+	}
+	return InstanceSize{}, errdefs.InvalidInputf("Pod %s needs %v which exceeds the largest instance size %v",
+		pod.Name, request, sizes[len(sizes)-1])
+}
+
+// sortInstanceSizes orders sizes cheapest first, by cpu and then memory.
+func sortInstanceSizes(sizes []InstanceSize) {
+	sort.Slice(sizes, func(i, j int) bool {
+		if sizes[i].Cpu != sizes[j].Cpu {
+			return sizes[i].Cpu < sizes[j].Cpu
+		}
+		return sizes[i].Memory < sizes[j].Memory
+	})
+}
+
+// needsRoom reports, per dimension, whether an app container sets nothing
+// while another sets a value.
+func needsRoom(pod *v1.Pod) (cpu, memory bool) {
+	var set, unset InstanceSize
+	for i := range pod.Spec.Containers {
+		s := containerCpuMemory(&pod.Spec.Containers[i])
+		set.Cpu += s.Cpu
+		set.Memory += s.Memory
+		if s.Cpu == 0 {
+			unset.Cpu++
+		}
+		if s.Memory == 0 {
+			unset.Memory++
+		}
+	}
+	return set.Cpu > 0 && unset.Cpu > 0, set.Memory > 0 && unset.Memory > 0
+}
+
+// containerLimit is what container may use: its own cpu and memory, or its
+// share of group where it sets none. App containers that set nothing split
+// evenly what the overhead and the other app containers leave, so the
+// containers never add up to more than the group. Init containers run one
+// at a time and may use all but the overhead.
+func (p *ECIProvider) containerLimit(pod *v1.Pod, container *v1.Container, init bool, group InstanceSize) InstanceSize {
+	limit := containerCpuMemory(container)
+	free := InstanceSize{Cpu: group.Cpu - p.overhead.Cpu, Memory: group.Memory - p.overhead.Memory}
+	if !init {
+		var unset InstanceSize
+		for i := range pod.Spec.Containers {
+			s := containerCpuMemory(&pod.Spec.Containers[i])
+			if s.Cpu == 0 {
+				unset.Cpu++
+			}
+			if s.Memory == 0 {
+				unset.Memory++
+			}
+			free.Cpu -= s.Cpu
+			free.Memory -= s.Memory
+		}
+		if unset.Cpu > 0 {
+			free.Cpu /= unset.Cpu
+		}
+		if unset.Memory > 0 {
+			free.Memory /= unset.Memory
+		}
+	}
+	// round down to a millicore and a MiB, shares never outgrow the group.
+	free.Cpu = math.Floor(free.Cpu*1000) / 1000
+	free.Memory = math.Floor(free.Memory*1024) / 1024
+	if limit.Cpu == 0 {
+		limit.Cpu = free.Cpu
+	}
+	if limit.Memory == 0 {
+		limit.Memory = free.Memory
+	}
+	return limit
+}
+
+// annotateInstanceSize records the chosen size on the pod, failures only
+// lose the annotation.
+func (p *ECIProvider) annotateInstanceSize(ctx context.Context, pod *v1.Pod, size InstanceSize) {
+	if p.kubeClient == nil {
+		return
+	}
+	patch := []byte(fmt.Sprintf(`{"metadata":{"annotations":{%q:%q}}}`, instanceSizeAnnotation, size.String()))
+	_, err := p.kubeClient.CoreV1().Pods(pod.Namespace).Patch(pod.Name, types.MergePatchType, patch)
+	if err != nil {
+		log.G(ctx).WithField("CDS", "CreatePod").Warn(fmt.Sprintf("annotate %s-%s: %v", pod.Namespace, pod.Name, err))
+	}
+}
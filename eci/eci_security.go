@@ -0,0 +1,168 @@
+package eci
+
+import (
+	"context"
+	"fmt"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	v1 "k8s.io/api/core/v1"
+	"strings"
+)
+
+// allowedCapabilities are the capabilities a container group may add, the
+// default set of the container runtime. Anything beyond needs privileges the
+// backend does not grant.
+var allowedCapabilities = map[string]bool{
+	"AUDIT_WRITE":      true,
+	"CHOWN":            true,
+	"DAC_OVERRIDE":     true,
+	"FOWNER":           true,
+	"FSETID":           true,
+	"KILL":             true,
+	"MKNOD":            true,
+	"NET_BIND_SERVICE": true,
+	"NET_RAW":          true,
+	"SETFCAP":          true,
+	"SETGID":           true,
+	"SETPCAP":          true,
+	"SETUID":           true,
+	"SYS_CHROOT":       true,
+}
+
+// getSecurityContext merges the pod and container security contexts, the
+// container settings win. Settings that would run the container with more
+// privileges than asked are rejected, see checkSecurity for the others.
+func getSecurityContext(pod *v1.Pod, container *v1.Container) (*SecurityContext, error) {
+	psc := pod.Spec.SecurityContext
+	csc := container.SecurityContext
+	if psc == nil && csc == nil {
+		return nil, nil
+	}
+	sc := &SecurityContext{}
+	if psc != nil {
+		sc.RunAsUser = psc.RunAsUser
+		sc.RunAsGroup = psc.RunAsGroup
+		sc.RunAsNonRoot = psc.RunAsNonRoot != nil && *psc.RunAsNonRoot
+	}
+	if csc == nil {
+		return sc, nil
+	}
+
+	if csc.Privileged != nil && *csc.Privileged {
+		return nil, fmt.Errorf("privileged container %s of Pod %s is unsupported", container.Name, pod.Name)
+	}
+	if csc.ProcMount != nil && *csc.ProcMount == v1.UnmaskedProcMount {
+		return nil, fmt.Errorf("unmasked procMount of container %s in Pod %s is unsupported", container.Name, pod.Name)
+	}
+	if csc.RunAsUser != nil {
+		sc.RunAsUser = csc.RunAsUser
+	}
+	if csc.RunAsGroup != nil {
+		sc.RunAsGroup = csc.RunAsGroup
+	}
+	if csc.RunAsNonRoot != nil {
+		sc.RunAsNonRoot = *csc.RunAsNonRoot
+	}
+	if sc.RunAsNonRoot && sc.RunAsUser != nil && *sc.RunAsUser == 0 {
+		return nil, fmt.Errorf("container %s of Pod %s must run as non-root but runAsUser is 0", container.Name, pod.Name)
+	}
+	sc.ReadOnlyRootFilesystem = csc.ReadOnlyRootFilesystem != nil && *csc.ReadOnlyRootFilesystem
+	sc.AllowPrivilegeEscalation = csc.AllowPrivilegeEscalation
+	if caps := csc.Capabilities; caps != nil {
+		for _, c := range caps.Add {
+			name := strings.TrimPrefix(strings.ToUpper(string(c)), "CAP_")
+			if !allowedCapabilities[name] {
+				return nil, fmt.Errorf("capability %s of container %s in Pod %s is unsupported", c, container.Name, pod.Name)
+			}
+			sc.CapabilitiesAdd = append(sc.CapabilitiesAdd, name)
+		}
+		for _, c := range caps.Drop {
+			sc.CapabilitiesDrop = append(sc.CapabilitiesDrop, strings.TrimPrefix(strings.ToUpper(string(c)), "CAP_"))
+		}
+	}
+	return sc, nil
+}
+
+// WithStrictSecurityContext makes pods fail when they set what the backend
+// can't apply, by default they run without it and get a warning event.
+func WithStrictSecurityContext(strict bool) ProviderOption {
+	return func(p *ECIProvider) {
+		p.strictSecurity = strict
+	}
+}
+
+// unsupportedSecurity lists the security settings of pod the backend has no
+// way to apply: it can't chown volumes, add groups, set sysctls or label
+// processes.
+func unsupportedSecurity(pod *v1.Pod) []string {
+	var settings []string
+	if psc := pod.Spec.SecurityContext; psc != nil {
+		if psc.FSGroup != nil {
+			settings = append(settings, "fsGroup")
+		}
+		if len(psc.SupplementalGroups) > 0 {
+			settings = append(settings, "supplementalGroups")
+		}
+		if len(psc.Sysctls) > 0 {
+			settings = append(settings, "sysctls")
+		}
+		if psc.SELinuxOptions != nil {
+			settings = append(settings, "seLinuxOptions")
+		}
+	}
+	for _, containers := range [][]v1.Container{pod.Spec.InitContainers, pod.Spec.Containers} {
+		for _, c := range containers {
+			if c.SecurityContext != nil && c.SecurityContext.SELinuxOptions != nil {
+				settings = append(settings, fmt.Sprintf("seLinuxOptions of container %s", c.Name))
+			}
+		}
+	}
+	return settings
+}
+
+// checkSecurity fails pod when it sets what the backend can't apply and the
+// provider is strict. Otherwise the pod runs without those settings, as it
+// did before they were looked at, and gets a warning.
+func (p *ECIProvider) checkSecurity(ctx context.Context, pod *v1.Pod) error {
+	settings := unsupportedSecurity(pod)
+	if len(settings) == 0 {
+		return nil
+	}
+	unsupported := strings.Join(settings, ", ")
+	if p.strictSecurity {
+		return fmt.Errorf("%s of Pod %s are unsupported", unsupported, pod.Name)
+	}
+	log.G(ctx).WithField("CDS", "CreatePod").Warn(fmt.Sprintf("Pod %s-%s runs without %s", pod.Namespace, pod.Name, unsupported))
+	if p.recorder != nil {
+		p.recorder.Eventf(pod, v1.EventTypeWarning, "UnsupportedSecurityContext", "container group runs without %s", unsupported)
+	}
+	return nil
+}
+
+// getLifecycle translates the postStart and preStop hooks of container.
+func getLifecycle(container *v1.Container) (*Lifecycle, error) {
+	if container.Lifecycle == nil {
+		return nil, nil
+	}
+	lifecycle := &Lifecycle{}
+	for _, hook := range []struct {
+		name    string
+		handler *v1.Handler
+		out     **Handler
+	}{
+		{"postStart", container.Lifecycle.PostStart, &lifecycle.PostStart},
+		{"preStop", container.Lifecycle.PreStop, &lifecycle.PreStop},
+	} {
+		if hook.handler == nil {
+			continue
+		}
+		if hook.handler.TCPSocket != nil {
+			return nil, fmt.Errorf("tcpSocket %s hook of container %s is unsupported", hook.name, container.Name)
+		}
+		handler, err := getHandler(container, *hook.handler)
+		if err != nil {
+			return nil, err
+		}
+		*hook.out = &handler
+	}
+	return lifecycle, nil
+}
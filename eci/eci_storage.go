@@ -0,0 +1,102 @@
+package eci
+
+import (
+	"fmt"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	v1 "k8s.io/api/core/v1"
+	"strconv"
+)
+
+// The annotations override the ephemeral disk derived from the pod resources.
+const (
+	storageTypeAnnotation = "eci-storage-type"
+	storageSizeAnnotation = "eci-storage-size"
+)
+
+const (
+	defaultStorageType = "high_disk"
+	// minStorageSize is the smallest ephemeral disk in GiB, it also holds
+	// the images.
+	minStorageSize = 20
+)
+
+// WithStorageTypes limits the eci-storage-type annotation to types, the
+// ephemeral disk types the backend offers. Without any every type is passed
+// on and the backend decides.
+func WithStorageTypes(types []string) ProviderOption {
+	return func(p *ECIProvider) {
+		p.storageTypes = make(map[string]bool, len(types))
+		for _, t := range types {
+			p.storageTypes[t] = true
+		}
+	}
+}
+
+// podStorage returns the ephemeral disk type and size in GiB of pod. The size
+// covers the ephemeral-storage of the containers, with the same init
+// container rule as cpu and memory, plus the sizeLimit of disk backed
+// emptyDirs.
+func (p *ECIProvider) podStorage(pod *v1.Pod) (string, int, error) {
+	t := pod.Annotations[storageTypeAnnotation]
+	if t == "" {
+		t = defaultStorageType
+	}
+	if len(p.storageTypes) > 0 && !p.storageTypes[t] {
+		return "", 0, errdefs.InvalidInputf("invalid %s %q of Pod %s", storageTypeAnnotation, t, pod.Name)
+	}
+
+	if s, ok := pod.Annotations[storageSizeAnnotation]; ok {
+		size, err := strconv.Atoi(s)
+		if err != nil || size <= 0 {
+			return "", 0, errdefs.InvalidInputf("invalid %s %q of Pod %s", storageSizeAnnotation, s, pod.Name)
+		}
+		return t, size, nil
+	}
+
+	var sum, initMax int64
+	for i := range pod.Spec.Containers {
+		sum += containerEphemeralStorage(&pod.Spec.Containers[i])
+	}
+	for i := range pod.Spec.InitContainers {
+		if s := containerEphemeralStorage(&pod.Spec.InitContainers[i]); s > initMax {
+			initMax = s
+		}
+	}
+	if initMax > sum {
+		sum = initMax
+	}
+	for _, v := range pod.Spec.Volumes {
+		if v.EmptyDir != nil && v.EmptyDir.Medium != v1.StorageMediumMemory && v.EmptyDir.SizeLimit != nil {
+			sum += v.EmptyDir.SizeLimit.Value()
+		}
+	}
+
+	size := int((sum + gib - 1) / gib)
+	if size < minStorageSize {
+		size = minStorageSize
+	}
+	return t, size, nil
+}
+
+// containerEphemeralStorage returns the ephemeral-storage bytes of container,
+// limits take precedence over requests.
+func containerEphemeralStorage(container *v1.Container) int64 {
+	if q, ok := container.Resources.Limits[v1.ResourceEphemeralStorage]; ok {
+		return q.Value()
+	}
+	if q, ok := container.Resources.Requests[v1.ResourceEphemeralStorage]; ok {
+		return q.Value()
+	}
+	return 0
+}
+
+// emptyDirSizeLimit returns the sizeLimit of a disk backed emptyDir in bytes.
+func emptyDirSizeLimit(source *v1.EmptyDirVolumeSource) (int64, error) {
+	if source.SizeLimit == nil {
+		return 0, nil
+	}
+	if source.SizeLimit.Sign() < 0 {
+		return 0, fmt.Errorf("negative emptyDir sizeLimit %v", source.SizeLimit)
+	}
+	return source.SizeLimit.Value(), nil
+}
@@ -0,0 +1,256 @@
+package eci
+
+import (
+	"context"
+	"fmt"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+	"hash/fnv"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// tokenRefreshInterval is how often the refresher looks for tokens close to expiry.
+	tokenRefreshInterval = time.Minute
+	// tokenMaxAge is when a token is refreshed even if it expires much later.
+	tokenMaxAge = 24 * time.Hour
+	// defaultTokenExpirationSeconds matches the API server default of a projection.
+	defaultTokenExpirationSeconds = 3600
+	// tokenStartSpread bounds how long after the refresher starts the pods
+	// with unknown tokens are spread over.
+	tokenStartSpread = 5 * time.Minute
+)
+
+// tokenManager requests service account tokens bound to pods and caches them
+// until 80% of their lifetime has passed, the same rule the kubelet uses.
+type tokenManager struct {
+	sync.Mutex
+	client corev1client.ServiceAccountsGetter
+	cache  map[string]*authenticationv1.TokenRequest
+}
+
+func newTokenManager(client corev1client.ServiceAccountsGetter) *tokenManager {
+	return &tokenManager{
+		client: client,
+		cache:  make(map[string]*authenticationv1.TokenRequest),
+	}
+}
+
+func tokenKey(pod *v1.Pod, source *v1.ServiceAccountTokenProjection) string {
+	return fmt.Sprintf("%s/%s/%s/%d/%s", pod.Namespace, serviceAccountName(pod), source.Audience,
+		tokenExpirationSeconds(source), pod.UID)
+}
+
+func serviceAccountName(pod *v1.Pod) string {
+	if pod.Spec.ServiceAccountName != "" {
+		return pod.Spec.ServiceAccountName
+	}
+	return "default"
+}
+
+func tokenExpirationSeconds(source *v1.ServiceAccountTokenProjection) int64 {
+	if source.ExpirationSeconds != nil {
+		return *source.ExpirationSeconds
+	}
+	return defaultTokenExpirationSeconds
+}
+
+// getToken returns a cached token for the projection or requests a new one.
+func (m *tokenManager) getToken(pod *v1.Pod, source *v1.ServiceAccountTokenProjection) (string, error) {
+	key := tokenKey(pod, source)
+	m.Lock()
+	tr, ok := m.cache[key]
+	m.Unlock()
+	if ok && !requiresRefresh(tr) {
+		return tr.Status.Token, nil
+	}
+	if m.client == nil {
+		return "", fmt.Errorf("no kubernetes client to request service account tokens")
+	}
+
+	expirationSeconds := tokenExpirationSeconds(source)
+	request := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: &expirationSeconds,
+			BoundObjectRef: &authenticationv1.BoundObjectReference{
+				APIVersion: "v1",
+				Kind:       "Pod",
+				Name:       pod.Name,
+				UID:        pod.UID,
+			},
+		},
+	}
+	if source.Audience != "" {
+		request.Spec.Audiences = []string{source.Audience}
+	}
+	tr, err := m.client.ServiceAccounts(pod.Namespace).CreateToken(serviceAccountName(pod), request)
+	if err != nil {
+		return "", fmt.Errorf("request token of service account %s: %v", serviceAccountName(pod), err)
+	}
+	m.Lock()
+	m.cache[key] = tr
+	m.Unlock()
+	return tr.Status.Token, nil
+}
+
+// needsRefresh reports whether any token projected into pod is due.
+func (m *tokenManager) needsRefresh(pod *v1.Pod) bool {
+	m.Lock()
+	defer m.Unlock()
+	for _, source := range tokenProjections(pod) {
+		tr, ok := m.cache[tokenKey(pod, source)]
+		if !ok || requiresRefresh(tr) {
+			return true
+		}
+	}
+	return false
+}
+
+// known reports whether any token projected into pod was issued by this
+// process.
+func (m *tokenManager) known(pod *v1.Pod) bool {
+	m.Lock()
+	defer m.Unlock()
+	for _, source := range tokenProjections(pod) {
+		if _, ok := m.cache[tokenKey(pod, source)]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// retain drops the tokens of pods that are gone.
+func (m *tokenManager) retain(uids map[types.UID]bool) {
+	m.Lock()
+	defer m.Unlock()
+	for key, tr := range m.cache {
+		if ref := tr.Spec.BoundObjectRef; ref == nil || !uids[ref.UID] {
+			delete(m.cache, key)
+		}
+	}
+}
+
+func requiresRefresh(tr *authenticationv1.TokenRequest) bool {
+	if tr.Spec.ExpirationSeconds == nil {
+		return false
+	}
+	now := time.Now()
+	ttl := time.Duration(*tr.Spec.ExpirationSeconds) * time.Second
+	exp := tr.Status.ExpirationTimestamp.Time
+	iat := exp.Add(-ttl)
+	return now.After(iat.Add(ttl*8/10)) || now.After(iat.Add(tokenMaxAge))
+}
+
+// tokenProjections returns the service account token sources of pod.
+func tokenProjections(pod *v1.Pod) []*v1.ServiceAccountTokenProjection {
+	var sources []*v1.ServiceAccountTokenProjection
+	for _, v := range pod.Spec.Volumes {
+		if v.Projected == nil {
+			continue
+		}
+		for _, s := range v.Projected.Sources {
+			if s.ServiceAccountToken != nil {
+				sources = append(sources, s.ServiceAccountToken)
+			}
+		}
+	}
+	return sources
+}
+
+func (p *ECIProvider) runTokenRefresher(ctx context.Context) {
+	started := time.Now()
+	ticker := time.NewTicker(tokenRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		p.refreshTokens(ctx, started)
+	}
+}
+
+// refreshDelay is how long after started a pod whose tokens are unknown, as
+// after a restart, waits for its first refresh. Pods are spread by their UID
+// over a tenth of their shortest token lifetime, at most tokenStartSpread,
+// so a restart does not push every group at once.
+func refreshDelay(pod *v1.Pod) time.Duration {
+	window := tokenStartSpread
+	for _, source := range tokenProjections(pod) {
+		if ttl := time.Duration(tokenExpirationSeconds(source)) * time.Second / 10; ttl < window {
+			window = ttl
+		}
+	}
+	if window <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(pod.UID))
+	return time.Duration(h.Sum64() % uint64(window))
+}
+
+// refreshTokens renders the projected volumes of pods with due tokens again
+// and pushes them to their container groups.
+func (p *ECIProvider) refreshTokens(ctx context.Context, started time.Time) {
+	pods := p.resourceManager.GetPods()
+	uids := make(map[types.UID]bool, len(pods))
+	for _, pod := range pods {
+		uids[pod.UID] = true
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if len(tokenProjections(pod)) == 0 || !p.tokens.needsRefresh(pod) {
+			continue
+		}
+		if !p.tokens.known(pod) && time.Since(started) < refreshDelay(pod) {
+			continue
+		}
+		// groups still being created get fresh tokens from CreatePod.
+		if _, ok := p.snapshotPod(pod.Namespace, pod.Name); !ok {
+			continue
+		}
+		if err := p.updateProjectedVolumes(ctx, pod); err != nil {
+			log.G(ctx).WithField("CDS", "RefreshTokens").Error(fmt.Sprintf("%s-%s: %v", pod.Namespace, pod.Name, err))
+		}
+	}
+	p.tokens.retain(uids)
+}
+
+func (p *ECIProvider) updateProjectedVolumes(ctx context.Context, pod *v1.Pod) error {
+	cgId := pod.Annotations["eci-instance-id"]
+	if cgId == "" {
+		cg, err := p.getCg(ctx, pod.Namespace, pod.Name)
+		if err != nil {
+			return err
+		}
+		cgId = cg.ContainerGroupId
+	}
+	request := UpdateContainerGroupVolume{ContainerGroupId: cgId}
+	for _, v := range pod.Spec.Volumes {
+		if v.Projected == nil {
+			continue
+		}
+		volume, err := p.projectedVolume(pod, v.Name, v.Projected)
+		if err != nil {
+			return err
+		}
+		request.Volumes = append(request.Volumes, volume)
+	}
+	cckRequest, _ := p.client.NewCCKRequest(ctx, UpdateContainerGroupVolumeAction, http.MethodPost, nil, request)
+	response, err := p.client.DoOpenApiRequest(ctx, cckRequest, 0)
+	if err != nil {
+		return err
+	}
+	_, err = p.client.CdsRespDeal(ctx, response, UpdateContainerGroupVolumeAction, nil)
+	if err != nil {
+		return err
+	}
+	log.G(ctx).WithField("CDS", "RefreshTokens").Debug(fmt.Sprintf("refreshed tokens of %s-%s", pod.Namespace, pod.Name))
+	return nil
+}
@@ -1,13 +1,15 @@
 package eci
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
-	"strconv"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -35,10 +37,12 @@ func containerGroupToPod(cg *ContainerGroup) (*v1.Pod, error) {
 
 	containers := make([]v1.Container, 0, len(cg.Containers))
 	containerStatuses := make([]v1.ContainerStatus, 0, len(cg.Containers))
-	for _, c := range cg.Containers {
+	allReady := len(cg.Containers) > 0
+	for i := range cg.Containers {
+		c := &cg.Containers[i]
 		container := v1.Container{
 			Name:    c.Name,
-			Image:   c.Image,
+			Image:   containerImage(c),
 			Command: c.Command,
 			Resources: v1.ResourceRequirements{
 				Requests: v1.ResourceList{
@@ -58,15 +62,16 @@ func containerGroupToPod(cg *ContainerGroup) (*v1.Pod, error) {
 			Name:                 c.Name,
 			State:                eciContainerStateToContainerState(c.CurrentState),
 			LastTerminationState: eciContainerStateToContainerState(c.PreviousState),
-			Ready:                eciStateToPodPhase(c.CurrentState.State) == v1.PodRunning,
+			Ready:                containerReady(c),
 			RestartCount:         int32(c.RestartCount),
-			Image:                c.Image,
-			ImageID:              "",
+			Image:                containerImage(c),
+			ImageID:              containerImageID(c),
 			ContainerID:          c.Id,
 		}
 
 		// Add to containerStatuses
 		containerStatuses = append(containerStatuses, containerStatus)
+		allReady = allReady && containerStatus.Ready
 	}
 
 	pod := v1.Pod{
@@ -91,7 +96,7 @@ func containerGroupToPod(cg *ContainerGroup) (*v1.Pod, error) {
 		},
 		Status: v1.PodStatus{
 			Phase:             eciStateToPodPhase(eciState),
-			Conditions:        eciStateToPodConditions(eciState, podCreationTimestamp),
+			Conditions:        eciStateToPodConditions(eciState, allReady, podCreationTimestamp),
 			Message:           cg.TaskState,
 			Reason:            "",
 			HostIP:            cg.IntranetIp,
@@ -121,13 +126,28 @@ func eciStateToPodPhase(state string) v1.PodPhase {
 	return v1.PodUnknown
 }
 
-func eciStateToPodConditions(state string, transitionTime metav1.Time) []v1.PodCondition {
+// eciStateToPodConditions derives the pod conditions, Ready follows the
+// readiness of the containers rather than the group state.
+func eciStateToPodConditions(state string, ready bool, transitionTime metav1.Time) []v1.PodCondition {
 	switch state {
 	case "Running", "Succeeded":
+		readyStatus, reason := v1.ConditionFalse, "ContainersNotReady"
+		if ready {
+			readyStatus, reason = v1.ConditionTrue, ""
+		}
+		if state == "Succeeded" {
+			readyStatus, reason = v1.ConditionFalse, "PodCompleted"
+		}
 		return []v1.PodCondition{
 			v1.PodCondition{
 				Type:               v1.PodReady,
-				Status:             v1.ConditionTrue,
+				Status:             readyStatus,
+				Reason:             reason,
+				LastTransitionTime: transitionTime,
+			}, v1.PodCondition{
+				Type:               v1.ContainersReady,
+				Status:             readyStatus,
+				Reason:             reason,
 				LastTransitionTime: transitionTime,
 			}, v1.PodCondition{
 				Type:               v1.PodInitialized,
@@ -193,22 +213,6 @@ func eciContainerStateToContainerState(cs *ContainerState) v1.ContainerState {
 	}
 }
 
-func makeStorageType(pod *v1.Pod) (t string, size int) {
-	t = pod.Annotations["eci-storage-type"]
-	s := pod.Annotations["eci-storage-size"]
-	if t == "" {
-		t = "high_disk"
-	}
-	if s == "" {
-		s = "20"
-	}
-	size, _ = strconv.Atoi(s)
-	if size == 0 {
-		size = 20
-	}
-	return t, size
-}
-
 func readDockerCfgSecret(secret *v1.Secret, ips []ImageRegistryCredential) ([]ImageRegistryCredential, error) {
 	var err error
 	var authConfigs map[string]AuthConfig
@@ -223,15 +227,7 @@ func readDockerCfgSecret(secret *v1.Secret, ips []ImageRegistryCredential) ([]Im
 		return ips, fmt.Errorf("failed to unmarshal auth config %+v", err)
 	}
 
-	for server, authConfig := range authConfigs {
-		ips = append(ips, ImageRegistryCredential{
-			Password: authConfig.Password,
-			Server:   server,
-			UserName: authConfig.Username,
-		})
-	}
-
-	return ips, err
+	return appendAuthConfigs(ips, authConfigs)
 }
 
 func readDockerConfigJSONSecret(secret *v1.Secret, ips []ImageRegistryCredential) ([]ImageRegistryCredential, error) {
@@ -255,15 +251,72 @@ func readDockerConfigJSONSecret(secret *v1.Secret, ips []ImageRegistryCredential
 		return ips, fmt.Errorf("malformed dockerconfigjson in secret")
 	}
 
-	for server, authConfig := range auths {
+	return appendAuthConfigs(ips, auths)
+}
+
+// appendAuthConfigs adds the credentials of auths in server order, the
+// base64 user:password of auth is used when username and password are absent.
+func appendAuthConfigs(ips []ImageRegistryCredential, auths map[string]AuthConfig) ([]ImageRegistryCredential, error) {
+	servers := make([]string, 0, len(auths))
+	for server := range auths {
+		servers = append(servers, server)
+	}
+	sort.Strings(servers)
+	for _, server := range servers {
+		authConfig := auths[server]
+		if authConfig.Username == "" && authConfig.Password == "" && authConfig.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(authConfig.Auth)
+			if err != nil {
+				return ips, fmt.Errorf("invalid auth of registry %s: %v", server, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return ips, fmt.Errorf("invalid auth of registry %s: not in user:password form", server)
+			}
+			authConfig.Username, authConfig.Password = parts[0], parts[1]
+		}
 		ips = append(ips, ImageRegistryCredential{
 			Password: authConfig.Password,
-			Server:   server,
+			Server:   normalizeRegistry(server),
 			UserName: authConfig.Username,
 		})
 	}
+	return ips, nil
+}
 
-	return ips, err
+// dockerHubAliases are the hosts docker config files use for Docker Hub.
+var dockerHubAliases = map[string]bool{
+	"index.docker.io":         true,
+	"registry-1.docker.io":    true,
+	"registry.hub.docker.com": true,
+}
+
+// normalizeRegistry reduces a docker config server key such as
+// https://index.docker.io/v1/ to the registry host, docker.io for Docker Hub.
+func normalizeRegistry(server string) string {
+	server = strings.TrimPrefix(strings.TrimPrefix(server, "https://"), "http://")
+	if i := strings.Index(server, "/"); i >= 0 {
+		server = server[:i]
+	}
+	server = strings.ToLower(server)
+	if dockerHubAliases[server] {
+		return defaultImageDomain
+	}
+	return server
+}
+
+// dedupeCredentials keeps the first credential of every registry.
+func dedupeCredentials(ips []ImageRegistryCredential) []ImageRegistryCredential {
+	seen := make(map[string]bool, len(ips))
+	out := ips[:0]
+	for _, ip := range ips {
+		if seen[ip.Server] {
+			continue
+		}
+		seen[ip.Server] = true
+		out = append(out, ip)
+	}
+	return out
 }
 
 func getSyncMapLength(m *sync.Map) int {
@@ -0,0 +1,134 @@
+package eci
+
+import (
+	"encoding/base64"
+	"fmt"
+	v1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// configMapVolume projects a configmap into a config file volume the way the
+// kubelet does. A missing configmap fails unless the volume is optional, in
+// which case the volume is mounted empty.
+func (p *ECIProvider) configMapVolume(pod *v1.Pod, name string, source *v1.ConfigMapVolumeSource) (Volume, error) {
+	volume := Volume{
+		Type:        VOL_TYPE_CONFIGFILEVOLUME,
+		Name:        name,
+		DefaultMode: fileMode(source.DefaultMode, v1.ConfigMapVolumeSourceDefaultMode),
+	}
+	configMap, err := p.resourceManager.GetConfigMap(source.Name, pod.Namespace)
+	if err != nil {
+		if k8serr.IsNotFound(err) && isOptional(source.Optional) {
+			volume.ConfigFileToPaths = []ConfigFileToPath{}
+			return volume, nil
+		}
+		return volume, refError("ConfigMap", source.Name, pod, err)
+	}
+	data := make(map[string][]byte, len(configMap.Data)+len(configMap.BinaryData))
+	for k, v := range configMap.Data {
+		data[k] = []byte(v)
+	}
+	for k, v := range configMap.BinaryData {
+		data[k] = v
+	}
+	volume.ConfigFileToPaths, err = projectKeys(data, source.Items, volume.DefaultMode, isOptional(source.Optional))
+	if err != nil {
+		return volume, fmt.Errorf("ConfigMap %s of Pod %s: %v", source.Name, pod.Name, err)
+	}
+	return volume, nil
+}
+
+// secretVolume projects a secret into a config file volume, see configMapVolume.
+func (p *ECIProvider) secretVolume(pod *v1.Pod, name string, source *v1.SecretVolumeSource) (Volume, error) {
+	volume := Volume{
+		Type:        VOL_TYPE_CONFIGFILEVOLUME,
+		Name:        name,
+		DefaultMode: fileMode(source.DefaultMode, v1.SecretVolumeSourceDefaultMode),
+	}
+	secret, err := p.resourceManager.GetSecret(source.SecretName, pod.Namespace)
+	if err != nil {
+		if k8serr.IsNotFound(err) && isOptional(source.Optional) {
+			volume.ConfigFileToPaths = []ConfigFileToPath{}
+			return volume, nil
+		}
+		return volume, refError("Secret", source.SecretName, pod, err)
+	}
+	volume.ConfigFileToPaths, err = projectKeys(secret.Data, source.Items, volume.DefaultMode, isOptional(source.Optional))
+	if err != nil {
+		return volume, fmt.Errorf("Secret %s of Pod %s: %v", source.SecretName, pod.Name, err)
+	}
+	return volume, nil
+}
+
+// projectKeys turns data into files. Without items every key becomes a file
+// named after it, otherwise only the listed keys are projected to their paths
+// and a missing key is an error unless optional is set.
+func projectKeys(data map[string][]byte, items []v1.KeyToPath, defaultMode int32, optional bool) ([]ConfigFileToPath, error) {
+	files := make([]ConfigFileToPath, 0, len(data))
+	if len(items) == 0 {
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			files = append(files, configFile(k, data[k], defaultMode))
+		}
+		return files, nil
+	}
+	for _, item := range items {
+		content, ok := data[item.Key]
+		if !ok {
+			if optional {
+				continue
+			}
+			return nil, fmt.Errorf("key %s is required and does not exist", item.Key)
+		}
+		if err := validateFilePath(item.Path); err != nil {
+			return nil, err
+		}
+		files = append(files, configFile(item.Path, content, fileMode(item.Mode, defaultMode)))
+	}
+	return files, nil
+}
+
+// configFile builds one file, content that is not valid utf-8 would be
+// mangled by json and is sent base64 encoded.
+func configFile(filePath string, content []byte, mode int32) ConfigFileToPath {
+	if utf8.Valid(content) {
+		return ConfigFileToPath{Path: filePath, Content: string(content), Mode: mode}
+	}
+	return ConfigFileToPath{
+		Path:     filePath,
+		Content:  base64.StdEncoding.EncodeToString(content),
+		Mode:     mode,
+		Encoding: CONTENT_ENCODING_BASE64,
+	}
+}
+
+// validateFilePath rejects paths that would escape the volume.
+func validateFilePath(filePath string) error {
+	if filePath == "" {
+		return fmt.Errorf("file path must not be empty")
+	}
+	if path.IsAbs(filePath) {
+		return fmt.Errorf("file path %s must be relative", filePath)
+	}
+	for _, part := range strings.Split(filePath, "/") {
+		if part == ".." {
+			return fmt.Errorf("file path %s must not contain '..'", filePath)
+		}
+	}
+	return nil
+}
+
+func fileMode(mode *int32, defaultMode int32) int32 {
+	if mode != nil {
+		return *mode
+	}
+	return defaultMode
+}
@@ -0,0 +1,25 @@
+package eci
+
+type ExecContainerCommandRequest struct {
+	ContainerGroupId string   `json:"container_group_id"`
+	ContainerName    string   `json:"container_name"`
+	Command          []string `json:"command"`
+	Tty              bool     `json:"tty"`
+	Stdin            bool     `json:"stdin"`
+}
+
+type ExecContainerCommandResponse struct {
+	WebSocketUri string `json:"web_socket_uri"`
+}
+
+// ExecStatus is sent on the status channel once the remote command exits.
+type ExecStatus struct {
+	ExitCode int    `json:"exit_code"`
+	Message  string `json:"message"`
+}
+
+// ExecTermSize is sent on the resize channel when the client terminal changes.
+type ExecTermSize struct {
+	Width  uint16 `json:"width"`
+	Height uint16 `json:"height"`
+}
@@ -1,21 +1,11 @@
 package eci
 
-import (
-	"os"
-)
-
-func init() {
-	NodeName = os.Getenv("DEFAULT_NODE_NAME")
-	if NodeName == "" {
-		NodeName = "cds-virtual-node"
-	}
-}
-
+// Where the virtual node is in CDS, set by the root command from its
+// configuration before the provider is created.
 var (
-	SiteId    = os.Getenv("SITE_ID")
-	ClusterId = os.Getenv("CLUSTER_ID")
-	NodeId    = os.Getenv("DEFAULT_NODE_ID")
+	SiteId    string
+	ClusterId string
+	NodeId    string
 	NodeName  string
-	PrivateId = os.Getenv("PRIVATE_ID")
-	MaxPods   = os.Getenv("MAX_PODS")
+	PrivateId string
 )
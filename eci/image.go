@@ -0,0 +1,111 @@
+package eci
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultImageDomain = "docker.io"
+	defaultImageTag    = "latest"
+)
+
+var (
+	imagePathComponent = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|[-]*)[a-z0-9]+)*$`)
+	imageTag           = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	imageDigest        = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}$`)
+)
+
+// imageRef is a parsed docker image reference.
+type imageRef struct {
+	// Name is the repository as written, e.g. nginx or registry.example.com:5000/team/app.
+	Name string
+	// Domain is the registry host and optional port, docker.io if none is given.
+	Domain string
+	// Path is the repository path in the registry, library/ is added for
+	// official images on docker.io.
+	Path   string
+	Tag    string
+	Digest string
+}
+
+// parseImageRef parses image with the docker reference rules: the first
+// component is a registry when it holds a '.' or ':' or is localhost, a tag
+// follows the last ':' after the last '/', and a digest follows '@'. Images
+// without tag or digest default to the latest tag.
+func parseImageRef(image string) (imageRef, error) {
+	var ref imageRef
+	if image == "" {
+		return ref, fmt.Errorf("image must not be empty")
+	}
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !imageDigest.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("invalid digest %q in image %s", ref.Digest, image)
+		}
+	}
+	if i := strings.LastIndex(name, ":"); i >= 0 && i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if !imageTag.MatchString(ref.Tag) {
+			return ref, fmt.Errorf("invalid tag %q in image %s", ref.Tag, image)
+		}
+	}
+	ref.Name = name
+
+	ref.Domain, ref.Path = defaultImageDomain, name
+	if i := strings.Index(name, "/"); i >= 0 {
+		first := name[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			ref.Domain, ref.Path = first, name[i+1:]
+		}
+	}
+	if ref.Domain == defaultImageDomain && !strings.Contains(ref.Path, "/") {
+		ref.Path = "library/" + ref.Path
+	}
+	for _, component := range strings.Split(ref.Path, "/") {
+		if !imagePathComponent.MatchString(component) {
+			return ref, fmt.Errorf("invalid repository %q in image %s", ref.Path, image)
+		}
+	}
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = defaultImageTag
+	}
+	return ref, nil
+}
+
+// String returns the reference with its tag and digest.
+func (r imageRef) String() string {
+	s := r.Name
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// containerImage rebuilds the image reference a container group runs.
+func containerImage(c *ContainerInfo) string {
+	ref := imageRef{Name: c.Image, Tag: c.ImageVersion, Digest: c.ImageDigest}
+	return ref.String()
+}
+
+// containerImageID reports the resolved image the way the kubelet does for
+// docker, falling back to the digest the pod asked for.
+func containerImageID(c *ContainerInfo) string {
+	switch {
+	case strings.Contains(c.ImageId, "@"):
+		return "docker-pullable://" + c.ImageId
+	case c.ImageId != "":
+		return "docker-pullable://" + c.Image + "@" + c.ImageId
+	case c.ImageDigest != "":
+		return "docker-pullable://" + c.Image + "@" + c.ImageDigest
+	}
+	return ""
+}
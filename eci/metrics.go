@@ -0,0 +1,31 @@
+package eci
+
+import (
+	"context"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+var (
+	resultTagKey, _ = tag.NewKey("result")
+
+	orphanCgs = stats.Int64("eci/orphan_container_groups", "Container groups without a Kubernetes pod handled by the reconciler", stats.UnitDimensionless)
+
+	// Views are the opencensus views of the provider metrics, they are
+	// registered by the root command.
+	Views = []*view.View{
+		{
+			Name:        "eci/orphan_container_groups",
+			Description: "Count of orphaned container groups by result: deleted, dry_run or failed",
+			Measure:     orphanCgs,
+			TagKeys:     []tag.Key{resultTagKey},
+			Aggregation: view.Count(),
+		},
+	}
+)
+
+func recordOrphan(ctx context.Context, result string) {
+	_ = stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(resultTagKey, result)}, orphanCgs.M(1))
+}
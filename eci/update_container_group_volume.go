@@ -0,0 +1,8 @@
+package eci
+
+// UpdateContainerGroupVolume replaces the content of config file volumes of a
+// running container group, e.g. to rotate projected service account tokens.
+type UpdateContainerGroupVolume struct {
+	ContainerGroupId string   `json:"container_group_id"`
+	Volumes          []Volume `json:"volumes"`
+}
@@ -0,0 +1,442 @@
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/capitalonline/cds-virtual-kubelet/cdsapi"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"io/ioutil"
+	"sigs.k8s.io/yaml"
+	"time"
+)
+
+// ConfigAPIVersion is the version of the configuration file format.
+const ConfigAPIVersion = "cds-virtual-kubelet/v1"
+
+// redactedValue replaces secrets when the configuration is printed.
+const redactedValue = "******"
+
+// Config is the configuration file, in YAML or JSON. Every field is
+// optional, the environment overrides the file and flags override both.
+type Config struct {
+	APIVersion string           `json:"apiVersion"`
+	Node       NodeConfig       `json:"node"`
+	Kubernetes KubernetesConfig `json:"kubernetes"`
+	API        APIConfig        `json:"api"`
+	Pods       PodsConfig       `json:"pods"`
+	Tracing    TracingConfig    `json:"tracing"`
+}
+
+type NodeConfig struct {
+	Name            string         `json:"name"`
+	Id              string         `json:"id"`
+	SiteId          string         `json:"siteId"`
+	ClusterId       string         `json:"clusterId"`
+	PrivateId       string         `json:"privateId"`
+	OperatingSystem string         `json:"operatingSystem"`
+	InternalIP      string         `json:"internalIP"`
+	ListenPort      int32          `json:"listenPort"`
+	MetricsAddr     string         `json:"metricsAddr"`
+	CertPath        string         `json:"certPath"`
+	KeyPath         string         `json:"keyPath"`
+	EnableNodeLease bool           `json:"enableNodeLease"`
+	Taints          []VKTaint      `json:"taints"`
+	Capacity        CapacityConfig `json:"capacity"`
+}
+
+type CapacityConfig struct {
+	CPU              string `json:"cpu"`
+	Memory           string `json:"memory"`
+	Pods             string `json:"pods"`
+	EphemeralStorage string `json:"ephemeralStorage"`
+}
+
+type KubernetesConfig struct {
+	Kubeconfig           string `json:"kubeconfig"`
+	MasterURI            string `json:"masterURI"`
+	Namespace            string `json:"namespace"`
+	InformerResyncPeriod string `json:"informerResyncPeriod"`
+	PodSyncWorkers       int    `json:"podSyncWorkers"`
+	StartupTimeout       string `json:"startupTimeout"`
+}
+
+type APIConfig struct {
+	Endpoint        string            `json:"endpoint"`
+	AccessKeyId     string            `json:"accessKeyId"`
+	AccessKeySecret string            `json:"accessKeySecret"`
+	CustomerId      string            `json:"customerId"`
+	UserId          string            `json:"userId"`
+	Timeout         string            `json:"timeout"`
+	ActionTimeouts  map[string]string `json:"actionTimeouts"`
+	Limits          LimitsConfig      `json:"limits"`
+	Pool            PoolConfig        `json:"pool"`
+	Retry           RetryConfig       `json:"retry"`
+	RedactFields    []string          `json:"redactFields"`
+	UnredactFields  []string          `json:"unredactFields"`
+}
+
+type LimitsConfig struct {
+	Create   LimitConfig `json:"create"`
+	Delete   LimitConfig `json:"delete"`
+	Describe LimitConfig `json:"describe"`
+}
+
+type LimitConfig struct {
+	QPS         float64 `json:"qps"`
+	Burst       int     `json:"burst"`
+	MaxInFlight int     `json:"maxInFlight"`
+}
+
+type PoolConfig struct {
+	MaxIdleConns        int    `json:"maxIdleConns"`
+	MaxIdleConnsPerHost int    `json:"maxIdleConnsPerHost"`
+	IdleConnTimeout     string `json:"idleConnTimeout"`
+	KeepAlive           string `json:"keepAlive"`
+	DialTimeout         string `json:"dialTimeout"`
+	TLSHandshakeTimeout string `json:"tlsHandshakeTimeout"`
+}
+
+type RetryConfig struct {
+	MaxAttempts       int     `json:"maxAttempts"`
+	BaseDelay         string  `json:"baseDelay"`
+	MaxDelay          string  `json:"maxDelay"`
+	Jitter            float64 `json:"jitter"`
+	TransportErrors   bool    `json:"transportErrors"`
+	RetryableStatuses []int   `json:"retryableStatuses"`
+}
+
+type PodsConfig struct {
+	InstanceSizes          []string          `json:"instanceSizes"`
+	DefaultInstanceSize    string            `json:"defaultInstanceSize"`
+	NamespaceInstanceSizes map[string]string `json:"namespaceInstanceSizes"`
+	Overhead               string            `json:"overhead"`
+	StorageTypes           []string          `json:"storageTypes"`
+	DaemonSetPolicy        string            `json:"daemonSetPolicy"`
+	DaemonSetAllowSelector string            `json:"daemonSetAllowSelector"`
+	// RejectUnsupportedSecurityContext fails pods setting security options
+	// the backend can't apply, they otherwise run without them.
+	RejectUnsupportedSecurityContext bool            `json:"rejectUnsupportedSecurityContext"`
+	Reconcile                        ReconcileConfig `json:"reconcile"`
+}
+
+type ReconcileConfig struct {
+	Interval          string `json:"interval"`
+	OrphanGracePeriod string `json:"orphanGracePeriod"`
+	DryRun            bool   `json:"dryRun"`
+}
+
+type TracingConfig struct {
+	Exporters   []string          `json:"exporters"`
+	SampleRate  string            `json:"sampleRate"`
+	ServiceName string            `json:"serviceName"`
+	Tags        map[string]string `json:"tags"`
+	ZpagesPort  string            `json:"zpagesPort"`
+	Jaeger      JaegerConfig      `json:"jaeger"`
+	OCAgent     OCAgentConfig     `json:"ocagent"`
+}
+
+type JaegerConfig struct {
+	Endpoint      string `json:"endpoint"`
+	AgentEndpoint string `json:"agentEndpoint"`
+	User          string `json:"user"`
+	Password      string `json:"password"`
+}
+
+type OCAgentConfig struct {
+	Endpoint string `json:"endpoint"`
+	Insecure bool   `json:"insecure"`
+}
+
+// LoadConfig applies the configuration file at path, if any, and then the
+// environment to c. Problems are kept for Validate, which reports them
+// together with invalid values.
+func LoadConfig(c *Opts, path string) {
+	c.ConfigPath = path
+	if path != "" {
+		c.loadErrs = append(c.loadErrs, loadConfigFile(c, path)...)
+	}
+	c.loadErrs = append(c.loadErrs, applyEnv(c)...)
+}
+
+// loadConfigFile decodes the file over the current options, so fields the
+// file leaves out keep their value. Unknown fields are errors.
+func loadConfigFile(c *Opts, path string) []error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("config file: %v", err)}
+	}
+	cfg := configFromOpts(c)
+	cfg.APIVersion = ""
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return []error{fmt.Errorf("config file %s: %v", path, err)}
+	}
+	if cfg.APIVersion != ConfigAPIVersion {
+		return []error{fmt.Errorf("config file %s: unsupported apiVersion %q, expected %s", path, cfg.APIVersion, ConfigAPIVersion)}
+	}
+	errs := cfg.applyTo(c)
+	for i, err := range errs {
+		errs[i] = fmt.Errorf("config file %s: %v", path, err)
+	}
+	return errs
+}
+
+// ConfigPathFromArgs finds --config in the command line before the flags
+// are parsed, the file supplies their defaults.
+func ConfigPathFromArgs(args []string) string {
+	flags := pflag.NewFlagSet("config", pflag.ContinueOnError)
+	flags.ParseErrorsWhitelist.UnknownFlags = true
+	flags.SetOutput(ioutil.Discard)
+	path := flags.String("config", "", "")
+	flags.BoolP("help", "h", false, "")
+	_ = flags.Parse(args)
+	return *path
+}
+
+func configFromOpts(c *Opts) Config {
+	limit := func(l cdsapi.Limit) LimitConfig {
+		return LimitConfig{QPS: l.QPS, Burst: l.Burst, MaxInFlight: l.MaxInFlight}
+	}
+	return Config{
+		APIVersion: ConfigAPIVersion,
+		Node: NodeConfig{
+			Name:            c.NodeName,
+			Id:              c.NodeId,
+			SiteId:          c.SiteId,
+			ClusterId:       c.ClusterId,
+			PrivateId:       c.PrivateId,
+			OperatingSystem: c.OperatingSystem,
+			InternalIP:      c.InternalIP,
+			ListenPort:      c.ListenPort,
+			MetricsAddr:     c.MetricsAddr,
+			CertPath:        c.CertPath,
+			KeyPath:         c.KeyPath,
+			EnableNodeLease: c.EnableNodeLease,
+			Taints:          c.Taints,
+			Capacity: CapacityConfig{
+				CPU:              c.CapacityCPU,
+				Memory:           c.CapacityMemory,
+				Pods:             c.CapacityPods,
+				EphemeralStorage: c.CapacityEphemeralStorage,
+			},
+		},
+		Kubernetes: KubernetesConfig{
+			Kubeconfig:           c.KubeConfigPath,
+			MasterURI:            c.MasterURI,
+			Namespace:            c.KubeNamespace,
+			InformerResyncPeriod: c.InformerResyncPeriod.String(),
+			PodSyncWorkers:       c.PodSyncWorkers,
+			StartupTimeout:       c.StartupTimeout.String(),
+		},
+		API: APIConfig{
+			Endpoint:        c.APIEndpoint,
+			AccessKeyId:     c.AccessKeyID,
+			AccessKeySecret: c.AccessKeySecret,
+			CustomerId:      c.CustomerID,
+			UserId:          c.UserID,
+			Timeout:         c.APITimeout.String(),
+			ActionTimeouts:  c.APIActionTimeouts,
+			Limits: LimitsConfig{
+				Create:   limit(c.CreateAPILimit),
+				Delete:   limit(c.DeleteAPILimit),
+				Describe: limit(c.DescribeAPILimit),
+			},
+			Pool: PoolConfig{
+				MaxIdleConns:        c.APIPool.MaxIdleConns,
+				MaxIdleConnsPerHost: c.APIPool.MaxIdleConnsPerHost,
+				IdleConnTimeout:     c.APIPool.IdleConnTimeout.String(),
+				KeepAlive:           c.APIPool.KeepAlive.String(),
+				DialTimeout:         c.APIPool.DialTimeout.String(),
+				TLSHandshakeTimeout: c.APIPool.TLSHandshakeTimeout.String(),
+			},
+			Retry: RetryConfig{
+				MaxAttempts:       c.APIMaxAttempts,
+				BaseDelay:         c.APIRetryBaseDelay.String(),
+				MaxDelay:          c.APIRetryMaxDelay.String(),
+				Jitter:            c.APIRetryJitter,
+				TransportErrors:   c.APIRetryTransportErrors,
+				RetryableStatuses: c.APIRetryableStatuses,
+			},
+			RedactFields:   c.RedactFields,
+			UnredactFields: c.UnredactFields,
+		},
+		Pods: PodsConfig{
+			InstanceSizes:                    c.InstanceSizes,
+			DefaultInstanceSize:              c.DefaultInstanceSize,
+			NamespaceInstanceSizes:           c.NamespaceInstanceSizes,
+			Overhead:                         c.PodOverhead,
+			StorageTypes:                     c.StorageTypes,
+			DaemonSetPolicy:                  c.DaemonSetPolicy,
+			DaemonSetAllowSelector:           c.DaemonSetAllowSelector,
+			RejectUnsupportedSecurityContext: c.RejectUnsupportedSecurity,
+			Reconcile: ReconcileConfig{
+				Interval:          c.Reconcile.Interval.String(),
+				OrphanGracePeriod: c.Reconcile.GracePeriod.String(),
+				DryRun:            c.Reconcile.DryRun,
+			},
+		},
+		Tracing: TracingConfig{
+			Exporters:   c.TraceExporters,
+			SampleRate:  c.TraceSampleRate,
+			ServiceName: c.TraceConfig.ServiceName,
+			Tags:        c.TraceConfig.Tags,
+			ZpagesPort:  c.TraceConfig.ZpagesPort,
+			Jaeger: JaegerConfig{
+				Endpoint:      c.TraceConfig.JaegerEndpoint,
+				AgentEndpoint: c.TraceConfig.JaegerAgentEndpoint,
+				User:          c.TraceConfig.JaegerUser,
+				Password:      c.TraceConfig.JaegerPassword,
+			},
+			OCAgent: OCAgentConfig{
+				Endpoint: c.TraceConfig.OCAgentEndpoint,
+				Insecure: c.TraceConfig.OCAgentInsecure,
+			},
+		},
+	}
+}
+
+// applyTo sets the options from cfg, returning every duration that does not
+// parse. Other values are checked by Validate.
+func (cfg *Config) applyTo(c *Opts) []error {
+	var errs []error
+	duration := func(field, v string, d *time.Duration) {
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid duration %q for %s", v, field))
+			return
+		}
+		*d = parsed
+	}
+	limit := func(l LimitConfig) cdsapi.Limit {
+		return cdsapi.Limit{QPS: l.QPS, Burst: l.Burst, MaxInFlight: l.MaxInFlight}
+	}
+
+	c.NodeName = cfg.Node.Name
+	c.NodeId = cfg.Node.Id
+	c.SiteId = cfg.Node.SiteId
+	c.ClusterId = cfg.Node.ClusterId
+	c.PrivateId = cfg.Node.PrivateId
+	c.OperatingSystem = cfg.Node.OperatingSystem
+	c.InternalIP = cfg.Node.InternalIP
+	c.ListenPort = cfg.Node.ListenPort
+	c.MetricsAddr = cfg.Node.MetricsAddr
+	c.CertPath = cfg.Node.CertPath
+	c.KeyPath = cfg.Node.KeyPath
+	c.EnableNodeLease = cfg.Node.EnableNodeLease
+	c.Taints = withDefaultTaint(cfg.Node.Taints)
+	c.CapacityCPU = cfg.Node.Capacity.CPU
+	c.CapacityMemory = cfg.Node.Capacity.Memory
+	c.CapacityPods = cfg.Node.Capacity.Pods
+	c.CapacityEphemeralStorage = cfg.Node.Capacity.EphemeralStorage
+
+	c.KubeConfigPath = cfg.Kubernetes.Kubeconfig
+	c.MasterURI = cfg.Kubernetes.MasterURI
+	c.KubeNamespace = cfg.Kubernetes.Namespace
+	duration("kubernetes.informerResyncPeriod", cfg.Kubernetes.InformerResyncPeriod, &c.InformerResyncPeriod)
+	c.PodSyncWorkers = cfg.Kubernetes.PodSyncWorkers
+	duration("kubernetes.startupTimeout", cfg.Kubernetes.StartupTimeout, &c.StartupTimeout)
+
+	c.APIEndpoint = cfg.API.Endpoint
+	c.AccessKeyID = cfg.API.AccessKeyId
+	c.AccessKeySecret = cfg.API.AccessKeySecret
+	c.CustomerID = cfg.API.CustomerId
+	c.UserID = cfg.API.UserId
+	duration("api.timeout", cfg.API.Timeout, &c.APITimeout)
+	c.APIActionTimeouts = cfg.API.ActionTimeouts
+	c.CreateAPILimit = limit(cfg.API.Limits.Create)
+	c.DeleteAPILimit = limit(cfg.API.Limits.Delete)
+	c.DescribeAPILimit = limit(cfg.API.Limits.Describe)
+	c.APIPool.MaxIdleConns = cfg.API.Pool.MaxIdleConns
+	c.APIPool.MaxIdleConnsPerHost = cfg.API.Pool.MaxIdleConnsPerHost
+	duration("api.pool.idleConnTimeout", cfg.API.Pool.IdleConnTimeout, &c.APIPool.IdleConnTimeout)
+	duration("api.pool.keepAlive", cfg.API.Pool.KeepAlive, &c.APIPool.KeepAlive)
+	duration("api.pool.dialTimeout", cfg.API.Pool.DialTimeout, &c.APIPool.DialTimeout)
+	duration("api.pool.tlsHandshakeTimeout", cfg.API.Pool.TLSHandshakeTimeout, &c.APIPool.TLSHandshakeTimeout)
+	c.APIMaxAttempts = cfg.API.Retry.MaxAttempts
+	duration("api.retry.baseDelay", cfg.API.Retry.BaseDelay, &c.APIRetryBaseDelay)
+	duration("api.retry.maxDelay", cfg.API.Retry.MaxDelay, &c.APIRetryMaxDelay)
+	c.APIRetryJitter = cfg.API.Retry.Jitter
+	c.APIRetryTransportErrors = cfg.API.Retry.TransportErrors
+	c.APIRetryableStatuses = cfg.API.Retry.RetryableStatuses
+	c.RedactFields = cfg.API.RedactFields
+	c.UnredactFields = cfg.API.UnredactFields
+
+	c.InstanceSizes = cfg.Pods.InstanceSizes
+	c.DefaultInstanceSize = cfg.Pods.DefaultInstanceSize
+	c.NamespaceInstanceSizes = cfg.Pods.NamespaceInstanceSizes
+	c.PodOverhead = cfg.Pods.Overhead
+	c.StorageTypes = cfg.Pods.StorageTypes
+	c.DaemonSetPolicy = cfg.Pods.DaemonSetPolicy
+	c.DaemonSetAllowSelector = cfg.Pods.DaemonSetAllowSelector
+	c.RejectUnsupportedSecurity = cfg.Pods.RejectUnsupportedSecurityContext
+	duration("pods.reconcile.interval", cfg.Pods.Reconcile.Interval, &c.Reconcile.Interval)
+	duration("pods.reconcile.orphanGracePeriod", cfg.Pods.Reconcile.OrphanGracePeriod, &c.Reconcile.GracePeriod)
+	c.Reconcile.DryRun = cfg.Pods.Reconcile.DryRun
+
+	c.TraceExporters = cfg.Tracing.Exporters
+	c.TraceSampleRate = cfg.Tracing.SampleRate
+	c.TraceConfig.ServiceName = cfg.Tracing.ServiceName
+	c.TraceConfig.Tags = cfg.Tracing.Tags
+	c.TraceConfig.ZpagesPort = cfg.Tracing.ZpagesPort
+	c.TraceConfig.JaegerEndpoint = cfg.Tracing.Jaeger.Endpoint
+	c.TraceConfig.JaegerAgentEndpoint = cfg.Tracing.Jaeger.AgentEndpoint
+	c.TraceConfig.JaegerUser = cfg.Tracing.Jaeger.User
+	c.TraceConfig.JaegerPassword = cfg.Tracing.Jaeger.Password
+	c.TraceConfig.OCAgentEndpoint = cfg.Tracing.OCAgent.Endpoint
+	c.TraceConfig.OCAgentInsecure = cfg.Tracing.OCAgent.Insecure
+	return errs
+}
+
+// redacted returns cfg with its secrets masked.
+func (cfg Config) redacted() Config {
+	for _, secret := range []*string{&cfg.API.AccessKeySecret, &cfg.Tracing.Jaeger.Password} {
+		if *secret != "" {
+			*secret = redactedValue
+		}
+	}
+	return cfg
+}
+
+// newConfigCommand creates the config command, its print subcommand shows
+// the options after the file, the environment and the flags are applied.
+func newConfigCommand(c *Opts) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+	var format string
+	printCmd := &cobra.Command{
+		Use:   "print",
+		Short: "Print the effective configuration with secrets redacted, then validate it",
+		Args:  cobra.NoArgs,
+		// validation errors are about the configuration, not the usage.
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := configFromOpts(c).redacted()
+			var (
+				out []byte
+				err error
+			)
+			switch format {
+			case "yaml":
+				out, err = yaml.Marshal(cfg)
+			case "json":
+				out, err = json.MarshalIndent(cfg, "", "  ")
+				out = append(out, '\n')
+			default:
+				return errdefs.InvalidInputf("unsupported format %q, expected yaml or json", format)
+			}
+			if err != nil {
+				return err
+			}
+			if _, err = cmd.OutOrStdout().Write(out); err != nil {
+				return err
+			}
+			return c.Validate()
+		},
+	}
+	printCmd.Flags().StringVarP(&format, "output", "o", "yaml", "output format, yaml or json")
+	cmd.AddCommand(printCmd)
+	return cmd
+}
@@ -1,6 +1,7 @@
 package root
 
 import (
+	"github.com/capitalonline/cds-virtual-kubelet/eci"
 	corev1 "k8s.io/api/core/v1"
 	"time"
 )
@@ -14,10 +15,29 @@ const (
 	DefaultListenPort           = 10250
 	DefaultPodSyncWorkers       = 100
 	DefaultKubeNamespace        = corev1.NamespaceAll
+	DefaultNodeName             = "cds-virtual-node"
 
 	DefaultTaintEffect = string(corev1.TaintEffectNoSchedule)
 	DefaultTaintKey    = "virtual-kubelet.io/provider"
 
+	// Client side budgets of the OpenAPI calls, per action class
+	DefaultCreateAPIQPS           = 10
+	DefaultCreateAPIBurst         = 20
+	DefaultCreateAPIMaxInFlight   = 20
+	DefaultDeleteAPIQPS           = 10
+	DefaultDeleteAPIBurst         = 20
+	DefaultDeleteAPIMaxInFlight   = 20
+	DefaultDescribeAPIQPS         = 20
+	DefaultDescribeAPIBurst       = 40
+	DefaultDescribeAPIMaxInFlight = 50
+
+	// DefaultPodOverhead is what the group runtime takes on top of the containers
+	DefaultPodOverhead = "0C0G"
+
+	// DefaultDaemonSetPolicy fakes DaemonSet pods, rejected ones are recreated
+	// by their controller in a loop
+	DefaultDaemonSetPolicy = string(eci.DaemonSetFake)
+
 	DefaultKubeConfig = "/home/cck/.kube/config"
 	DefaultCertPath   = "/etc/kubernetes/pki/ca.crt"
 	DefaultPathPath   = "/etc/kubernetes/pki/ca.key"
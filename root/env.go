@@ -0,0 +1,90 @@
+package root
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// envVar is an environment variable read over the configuration file. Empty
+// variables count as unset unless allowEmpty, e.g. an empty CERT_PATH
+// serves without TLS. The gateway variables PRE_IP, DEV_IP and CDS_OVERSEA
+// are read by cdsapi alone and have no configuration file field.
+type envVar struct {
+	name       string
+	allowEmpty bool
+	set        func(c *Opts, v string) error
+}
+
+var envVars = []envVar{
+	{name: "DEFAULT_NODE_NAME", set: func(c *Opts, v string) error { c.NodeName = v; return nil }},
+	{name: "DEFAULT_NODE_ID", set: func(c *Opts, v string) error { c.NodeId = v; return nil }},
+	{name: "SITE_ID", set: func(c *Opts, v string) error { c.SiteId = v; return nil }},
+	{name: "CLUSTER_ID", set: func(c *Opts, v string) error { c.ClusterId = v; return nil }},
+	{name: "PRIVATE_ID", set: func(c *Opts, v string) error { c.PrivateId = v; return nil }},
+	{name: "POD_IP", set: func(c *Opts, v string) error { c.InternalIP = v; return nil }},
+	{name: "MAX_PODS", set: func(c *Opts, v string) error { c.CapacityPods = v; return nil }},
+	{name: "WORKERS", set: func(c *Opts, v string) error {
+		workers, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid number of workers %q", v)
+		}
+		c.PodSyncWorkers = workers
+		return nil
+	}},
+	{name: "TAINTS", set: func(c *Opts, v string) error {
+		var taints []VKTaint
+		if err := json.Unmarshal([]byte(v), &taints); err != nil {
+			return fmt.Errorf("invalid taints, expected a json list of {key, value, effect}: %v", err)
+		}
+		// the list replaces the file's taints, the provider taint stays
+		// unless the list sets its own for the same key.
+		c.Taints = withDefaultTaint(taints)
+		return nil
+	}},
+	{name: "KUBECONFIG", allowEmpty: true, set: func(c *Opts, v string) error { c.KubeConfigPath = v; return nil }},
+	{name: "MASTER_URI", set: func(c *Opts, v string) error { c.MasterURI = v; return nil }},
+	{name: "CERT_PATH", allowEmpty: true, set: func(c *Opts, v string) error { c.CertPath = v; return nil }},
+	{name: "KEY_PATH", allowEmpty: true, set: func(c *Opts, v string) error { c.KeyPath = v; return nil }},
+
+	{name: "OPENAPI_HOST", set: func(c *Opts, v string) error { c.APIEndpoint = v; return nil }},
+	{name: "CDS_ACCESS_KEY_ID", set: func(c *Opts, v string) error { c.AccessKeyID = v; return nil }},
+	{name: "CDS_ACCESS_KEY_SECRET", set: func(c *Opts, v string) error { c.AccessKeySecret = v; return nil }},
+	{name: "CUSTOMER_ID", set: func(c *Opts, v string) error { c.CustomerID = v; return nil }},
+	{name: "USER_ID", set: func(c *Opts, v string) error { c.UserID = v; return nil }},
+
+	{name: "ZPAGES_PORT", set: func(c *Opts, v string) error { c.TraceConfig.ZpagesPort = v; return nil }},
+	{name: "JAEGER_ENDPOINT", set: func(c *Opts, v string) error { c.TraceConfig.JaegerEndpoint = v; return nil }},
+	{name: "JAEGER_AGENT_ENDPOINT", set: func(c *Opts, v string) error { c.TraceConfig.JaegerAgentEndpoint = v; return nil }},
+	{name: "JAEGER_USER", set: func(c *Opts, v string) error { c.TraceConfig.JaegerUser = v; return nil }},
+	{name: "JAEGER_PASSWORD", set: func(c *Opts, v string) error { c.TraceConfig.JaegerPassword = v; return nil }},
+	{name: "OCAGENT_ENDPOINT", set: func(c *Opts, v string) error { c.TraceConfig.OCAgentEndpoint = v; return nil }},
+	{name: "OCAGENT_INSECURE", set: func(c *Opts, v string) error {
+		switch v {
+		case "0", "no", "n", "off":
+			c.TraceConfig.OCAgentInsecure = false
+		case "1", "yes", "y", "on":
+			c.TraceConfig.OCAgentInsecure = true
+		default:
+			return fmt.Errorf("invalid value %q, expected yes or no", v)
+		}
+		return nil
+	}},
+}
+
+// applyEnv sets the options given in the environment, returning every
+// variable that does not parse.
+func applyEnv(c *Opts) []error {
+	var errs []error
+	for _, e := range envVars {
+		v, ok := os.LookupEnv(e.name)
+		if !ok || (v == "" && !e.allowEmpty) {
+			continue
+		}
+		if err := e.set(c, v); err != nil {
+			errs = append(errs, fmt.Errorf("env %s: %v", e.name, err))
+		}
+	}
+	return errs
+}
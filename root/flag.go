@@ -3,8 +3,8 @@ package root
 import (
 	"flag"
 	"fmt"
+	"github.com/capitalonline/cds-virtual-kubelet/cdsapi"
 	"k8s.io/klog"
-	"os"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -44,6 +44,7 @@ func (mv mapVar) Type() string {
 }
 
 func installFlags(flags *pflag.FlagSet, c *Opts) {
+	flags.StringVar(&c.ConfigPath, "config", c.ConfigPath, fmt.Sprintf("configuration file in YAML or JSON, apiVersion %s, overridden by the environment and flags", ConfigAPIVersion))
 	flags.BoolVar(&c.EnableNodeLease, "enable-node-lease", c.EnableNodeLease, `use node leases (1.13) for node heartbeats`)
 	flags.StringSliceVar(&c.TraceExporters, "trace-exporter", c.TraceExporters, fmt.Sprintf("sets the tracing exporter to use, available exporters: %s", AvailableTraceExporters()))
 	flags.StringVar(&c.TraceConfig.ServiceName, "trace-service-name", c.TraceConfig.ServiceName, "sets the name of the service used to register with the trace exporter")
@@ -52,6 +53,47 @@ func installFlags(flags *pflag.FlagSet, c *Opts) {
 
 	flags.DurationVar(&c.StartupTimeout, "startup-timeout", c.StartupTimeout, "How long to wait for the virtual-kubelet to start")
 
+	installAPILimitFlags(flags, "create", &c.CreateAPILimit)
+	installAPILimitFlags(flags, "delete", &c.DeleteAPILimit)
+	installAPILimitFlags(flags, "describe", &c.DescribeAPILimit)
+
+	flags.IntVar(&c.APIMaxAttempts, "api-max-attempts", c.APIMaxAttempts, "attempts of one OpenAPI request, 1 disables retries")
+	flags.DurationVar(&c.APIRetryBaseDelay, "api-retry-base-delay", c.APIRetryBaseDelay, "delay before the first OpenAPI retry, doubled on every retry")
+	flags.DurationVar(&c.APIRetryMaxDelay, "api-retry-max-delay", c.APIRetryMaxDelay, "longest delay between OpenAPI retries")
+	flags.Float64Var(&c.APIRetryJitter, "api-retry-jitter", c.APIRetryJitter, "fraction every OpenAPI retry delay is randomized by in either direction, 0 to disable")
+	flags.BoolVar(&c.APIRetryTransportErrors, "api-retry-transport-errors", c.APIRetryTransportErrors, "retry OpenAPI requests failing on timeouts, resets, refused connections or unexpected EOF")
+	flags.IntSliceVar(&c.APIRetryableStatuses, "api-retryable-status", c.APIRetryableStatuses, "http statuses of OpenAPI responses that are retried")
+	flags.DurationVar(&c.APITimeout, "api-timeout", c.APITimeout, "timeout of one OpenAPI request attempt, 0 to disable")
+	flags.Var(mapVar(c.APIActionTimeouts), "api-action-timeout", "timeout of one OpenAPI action in action=duration form, e.g. CreateContainerGroup=60s")
+	flags.IntVar(&c.APIPool.MaxIdleConns, "api-max-idle-conns", c.APIPool.MaxIdleConns, "max idle connections to the OpenAPI gateway")
+	flags.IntVar(&c.APIPool.MaxIdleConnsPerHost, "api-max-idle-conns-per-host", c.APIPool.MaxIdleConnsPerHost, "max idle connections per OpenAPI gateway host")
+	flags.DurationVar(&c.APIPool.IdleConnTimeout, "api-idle-conn-timeout", c.APIPool.IdleConnTimeout, "how long an idle OpenAPI connection is kept")
+	flags.DurationVar(&c.APIPool.KeepAlive, "api-keepalive", c.APIPool.KeepAlive, "tcp keep-alive period of OpenAPI connections")
+	flags.DurationVar(&c.APIPool.DialTimeout, "api-dial-timeout", c.APIPool.DialTimeout, "timeout to connect to the OpenAPI gateway")
+	flags.DurationVar(&c.APIPool.TLSHandshakeTimeout, "api-tls-handshake-timeout", c.APIPool.TLSHandshakeTimeout, "timeout of the TLS handshake with the OpenAPI gateway")
+
+	flags.StringSliceVar(&c.RedactFields, "redact-field", c.RedactFields, "additional json field or query param masked in OpenAPI logs, dotted names match a field path")
+	flags.StringSliceVar(&c.UnredactFields, "unredact-field", c.UnredactFields, "json field or query param never masked in OpenAPI logs")
+
+	flags.StringSliceVar(&c.InstanceSizes, "instance-size", c.InstanceSizes, "container group size the backend sells, e.g. 2C4G, pod requests are rounded up to these and sent as they are when none are given")
+	flags.StringVar(&c.DefaultInstanceSize, "default-instance-size", c.DefaultInstanceSize, "size of pods that request no cpu or memory")
+	flags.Var(mapVar(c.NamespaceInstanceSizes), "namespace-instance-size", "default size of a namespace in namespace=size form, e.g. batch=4C8G")
+	flags.StringSliceVar(&c.StorageTypes, "storage-type", c.StorageTypes, "ephemeral disk type pods may ask for in the eci-storage-type annotation, any type is passed to the backend when none are given")
+	flags.StringVar(&c.PodOverhead, "pod-overhead", c.PodOverhead, "cpu and memory a container group takes on top of its containers")
+
+	flags.StringVar(&c.DaemonSetPolicy, "daemonset-policy", c.DaemonSetPolicy, "what DaemonSet pods get: fake reports them Running without compute, reject fails them and their controller keeps recreating them")
+	flags.BoolVar(&c.RejectUnsupportedSecurity, "reject-unsupported-security-context", c.RejectUnsupportedSecurity, "fail pods setting fsGroup, supplementalGroups, sysctls or seLinuxOptions instead of running them without and warning")
+	flags.StringVar(&c.DaemonSetAllowSelector, "daemonset-allow-selector", c.DaemonSetAllowSelector, "label selector of DaemonSet pods that run as container groups regardless of the policy")
+
+	flags.StringVar(&c.CapacityCPU, "capacity-cpu", c.CapacityCPU, "cpu capacity of the node")
+	flags.StringVar(&c.CapacityMemory, "capacity-memory", c.CapacityMemory, "memory capacity of the node")
+	flags.StringVar(&c.CapacityPods, "capacity-pods", c.CapacityPods, "max pods of the node, defaults to $MAX_PODS")
+	flags.StringVar(&c.CapacityEphemeralStorage, "capacity-ephemeral-storage", c.CapacityEphemeralStorage, "ephemeral storage capacity of the node")
+
+	flags.DurationVar(&c.Reconcile.Interval, "reconcile-interval", c.Reconcile.Interval, "how often container groups are matched against pods, 0 to disable orphan collection")
+	flags.DurationVar(&c.Reconcile.GracePeriod, "orphan-grace-period", c.Reconcile.GracePeriod, "how long a container group stays without a pod before it is deleted")
+	flags.BoolVar(&c.Reconcile.DryRun, "orphan-gc-dry-run", c.Reconcile.DryRun, "report orphan container groups without deleting them")
+
 	flagset := flag.NewFlagSet("klog", flag.PanicOnError)
 	klog.InitFlags(flagset)
 	flagset.VisitAll(func(f *flag.Flag) {
@@ -60,10 +102,9 @@ func installFlags(flags *pflag.FlagSet, c *Opts) {
 	})
 }
 
-func getEnv(key, defaultValue string) string {
-	value, found := os.LookupEnv(key)
-	if found {
-		return value
-	}
-	return defaultValue
+// installAPILimitFlags adds the --<class>-api-* flags of one action class budget.
+func installAPILimitFlags(flags *pflag.FlagSet, class string, l *cdsapi.Limit) {
+	flags.Float64Var(&l.QPS, class+"-api-qps", l.QPS, fmt.Sprintf("max %s OpenAPI requests per second, 0 for unlimited", class))
+	flags.IntVar(&l.Burst, class+"-api-burst", l.Burst, fmt.Sprintf("burst of %s OpenAPI requests above the qps", class))
+	flags.IntVar(&l.MaxInFlight, class+"-api-max-inflight", l.MaxInFlight, fmt.Sprintf("max concurrent %s OpenAPI requests, 0 for unlimited", class))
 }
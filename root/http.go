@@ -77,6 +77,9 @@ func setupHTTPServer(ctx context.Context, p providers.Provider, cfg *apiServerCo
 			GetPods:          p.GetPods,
 		}
 		api.AttachPodRoutes(podRoutes, mux, true)
+		if ls, ok := p.(containerLogStreamer); ok {
+			mux.Handle("/containerLogs/", api.InstrumentHandler(handleContainerLogs(ls)))
+		}
 
 		s := &http.Server{
 			Handler:   mux,
@@ -0,0 +1,142 @@
+package root
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/capitalonline/cds-virtual-kubelet/eci"
+	"github.com/pkg/errors"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	"github.com/virtual-kubelet/virtual-kubelet/log"
+)
+
+// containerLogStreamer is implemented by providers that accept the full set of
+// kubelet log options, api.HandleContainerLogs only passes tailLines through.
+type containerLogStreamer interface {
+	StreamContainerLogs(ctx context.Context, namespace, podName, containerName string, opts eci.ContainerLogOpts) (io.ReadCloser, error)
+}
+
+// handleContainerLogs serves /containerLogs/{namespace}/{pod}/{container}.
+func handleContainerLogs(p containerLogStreamer) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if err := serveContainerLogs(w, req, p); err != nil {
+			code := http.StatusInternalServerError
+			switch {
+			case errdefs.IsNotFound(err):
+				code = http.StatusNotFound
+			case errdefs.IsInvalidInput(err):
+				code = http.StatusBadRequest
+			}
+			w.WriteHeader(code)
+			_, _ = io.WriteString(w, err.Error())
+			log.G(req.Context()).WithError(err).WithField("httpStatusCode", code).Debug("Error on request")
+		}
+	}
+}
+
+func serveContainerLogs(w http.ResponseWriter, req *http.Request, p containerLogStreamer) error {
+	if req.Method != http.MethodGet {
+		return errdefs.InvalidInputf("method %s not allowed", req.Method)
+	}
+	vars := strings.Split(strings.Trim(strings.TrimPrefix(req.URL.Path, "/containerLogs/"), "/"), "/")
+	if len(vars) != 3 {
+		return errdefs.NotFound("not found")
+	}
+
+	opts, err := getContainerLogOpts(req)
+	if err != nil {
+		return err
+	}
+
+	ctx := req.Context()
+	logs, err := p.StreamContainerLogs(ctx, vars[0], vars[1], vars[2], opts)
+	if err != nil {
+		return errors.Wrap(err, "error getting container logs")
+	}
+	defer logs.Close()
+
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, rerr := logs.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return nil
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if rerr == io.EOF {
+			return nil
+		}
+		if rerr != nil {
+			log.G(ctx).WithError(rerr).Debug("container log stream closed")
+			return nil
+		}
+	}
+}
+
+// getContainerLogOpts parses the v1.PodLogOptions query parameters.
+func getContainerLogOpts(req *http.Request) (eci.ContainerLogOpts, error) {
+	var opts eci.ContainerLogOpts
+	q := req.URL.Query()
+
+	intParam := func(name string) (int, error) {
+		v := q.Get(name)
+		if v == "" {
+			return 0, nil
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil || i < 0 {
+			return 0, errdefs.InvalidInputf("could not parse %q: %s", name, v)
+		}
+		return i, nil
+	}
+	boolParam := func(name string) (bool, error) {
+		v := q.Get(name)
+		if v == "" {
+			return false, nil
+		}
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return false, errdefs.InvalidInputf("could not parse %q: %s", name, v)
+		}
+		return b, nil
+	}
+
+	var err error
+	if opts.Tail, err = intParam("tailLines"); err != nil {
+		return opts, err
+	}
+	if opts.SinceSeconds, err = intParam("sinceSeconds"); err != nil {
+		return opts, err
+	}
+	if opts.LimitBytes, err = intParam("limitBytes"); err != nil {
+		return opts, err
+	}
+	if v := q.Get("sinceTime"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return opts, errdefs.InvalidInputf("could not parse \"sinceTime\": %s", v)
+		}
+		opts.SinceTime = &t
+	}
+	if opts.SinceSeconds > 0 && opts.SinceTime != nil {
+		return opts, errdefs.InvalidInput("at most one of \"sinceTime\" or \"sinceSeconds\" may be specified")
+	}
+	if opts.Timestamps, err = boolParam("timestamps"); err != nil {
+		return opts, err
+	}
+	if opts.Previous, err = boolParam("previous"); err != nil {
+		return opts, err
+	}
+	if opts.Follow, err = boolParam("follow"); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
@@ -9,8 +9,15 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 )
 
+// allocatableProvider is implemented by providers whose allocatable resources
+// differ from their capacity.
+type allocatableProvider interface {
+	Allocatable(context.Context) v1.ResourceList
+}
+
 // NodeFromProvider builds a kubernetes node object from a provider
 // This is a temporary solution until node stuff actually split off from the provider interface itself.
 func NodeFromProvider(ctx context.Context, name string, taints []v1.Taint, p providers.Provider, version string) *v1.Node {
@@ -41,11 +48,15 @@ func NodeFromProvider(ctx context.Context, name string, taints []v1.Taint, p pro
 			DaemonEndpoints: *p.NodeDaemonEndpoints(ctx),
 		},
 	}
+	if ap, ok := p.(allocatableProvider); ok {
+		node.Status.Allocatable = ap.Allocatable(ctx)
+	}
 	return node
 }
 
 func getTaint(c Opts) ([]v1.Taint, error) {
 	var taints []v1.Taint
+	var errs []error
 	for _, v := range c.Taints {
 		var effect corev1.TaintEffect
 		switch v.Effect {
@@ -56,7 +67,12 @@ func getTaint(c Opts) ([]v1.Taint, error) {
 		case "PreferNoSchedule":
 			effect = corev1.TaintEffectPreferNoSchedule
 		default:
-			return nil, errdefs.InvalidInputf("taint effect %q is not supported", v.Effect)
+			errs = append(errs, errdefs.InvalidInputf("taint effect %q of %s is not supported", v.Effect, v.Key))
+			continue
+		}
+		if v.Key == "" {
+			errs = append(errs, errdefs.InvalidInput("taint key must not be empty"))
+			continue
 		}
 		taints = append(taints, v1.Taint{
 			Key:       v.Key,
@@ -65,5 +81,8 @@ func getTaint(c Opts) ([]v1.Taint, error) {
 			TimeAdded: nil,
 		})
 	}
+	if len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
 	return taints, nil
 }
@@ -1,10 +1,12 @@
 package root
 
 import (
-	"encoding/json"
+	"github.com/capitalonline/cds-virtual-kubelet/cdsapi"
 	"github.com/capitalonline/cds-virtual-kubelet/eci"
-	"os"
-	"strconv"
+	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
+	ps "github.com/virtual-kubelet/virtual-kubelet/providers"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sort"
 	"time"
 )
 
@@ -14,8 +16,13 @@ import (
 // You can set the default options by creating a new `Opts` struct and passing
 // it into `SetDefaultOpts`
 type Opts struct {
+	// Path of the configuration file the options were loaded from
+	ConfigPath string
+
 	// Path to the kubeconfig to use to connect to the Kubernetes API server.
 	KubeConfigPath string
+	// Overrides the API server of the kubeconfig
+	MasterURI string
 	// Namespace to watch for pods and other resources
 	KubeNamespace string
 	// Sets the port to listen for requests from the Kubernetes API server
@@ -25,6 +32,14 @@ type Opts struct {
 	NodeName string
 	NodeId   string
 
+	// Where the node is in CDS
+	SiteId    string
+	ClusterId string
+	PrivateId string
+
+	// Address of the virtual kubelet reported on the node
+	InternalIP string
+
 	// Operating system to run pods for
 	OperatingSystem string
 
@@ -41,6 +56,63 @@ type Opts struct {
 	PodSyncWorkers       int
 	InformerResyncPeriod time.Duration
 
+	// OpenAPI gateway and the account calls are made for
+	APIEndpoint     string
+	AccessKeyID     string
+	AccessKeySecret string
+	CustomerID      string
+	UserID          string
+
+	// Client side budgets of the OpenAPI calls per action class
+	CreateAPILimit   cdsapi.Limit
+	DeleteAPILimit   cdsapi.Limit
+	DescribeAPILimit cdsapi.Limit
+
+	// Timeout of one OpenAPI request attempt, overridable per action
+	APITimeout        time.Duration
+	APIActionTimeouts map[string]string
+	APIPool           cdsapi.PoolOptions
+
+	// Retries of failed OpenAPI attempts, the delay doubles from base to max
+	APIMaxAttempts          int
+	APIRetryBaseDelay       time.Duration
+	APIRetryMaxDelay        time.Duration
+	APIRetryableStatuses    []int
+	APIRetryJitter          float64
+	APIRetryTransportErrors bool
+
+	// Extra fields masked in, and fields exempted from, the OpenAPI logs
+	RedactFields   []string
+	UnredactFields []string
+
+	// Container group sizes in 2C4G form, pod requests are rounded up to
+	// InstanceSizes, the sizes the backend sells, or sent as they are when
+	// none are given. Pods requesting nothing get the namespace or default size.
+	InstanceSizes          []string
+	DefaultInstanceSize    string
+	NamespaceInstanceSizes map[string]string
+	PodOverhead            string
+
+	// Ephemeral disk types pods may ask for, any when empty
+	StorageTypes []string
+
+	// What happens to DaemonSet pods, those matching the selector run anyway
+	DaemonSetPolicy        string
+	DaemonSetAllowSelector string
+
+	// Fail pods setting security options the backend can't apply rather
+	// than run them without
+	RejectUnsupportedSecurity bool
+
+	// Capacity of the node as quantities
+	CapacityCPU              string
+	CapacityMemory           string
+	CapacityPods             string
+	CapacityEphemeralStorage string
+
+	// Orphan container group collection, an interval of 0 disables it
+	Reconcile eci.ReconcileOptions
+
 	// Use node leases when supported by Kubernetes (instead of node status updates)
 	EnableNodeLease bool
 
@@ -55,6 +127,10 @@ type Opts struct {
 	KeyPath  string
 
 	Version string
+
+	// loadErrs are the problems found loading the file and the environment,
+	// Validate reports them with the others.
+	loadErrs []error
 }
 
 type VKTaint struct {
@@ -63,53 +139,144 @@ type VKTaint struct {
 	Effect string `json:"effect"`
 }
 
+// defaultTaint keeps pods off the virtual node unless they tolerate it.
+func defaultTaint() VKTaint {
+	return VKTaint{
+		Key:    DefaultTaintKey,
+		Value:  ProviderName,
+		Effect: DefaultTaintEffect,
+	}
+}
+
+// withDefaultTaint puts the provider taint in front of taints unless they
+// set their own for its key.
+func withDefaultTaint(taints []VKTaint) []VKTaint {
+	for _, t := range taints {
+		if t.Key == DefaultTaintKey {
+			return taints
+		}
+	}
+	return append([]VKTaint{defaultTaint()}, taints...)
+}
+
 // SetDefaultOpts sets default options for unset values on the passed in option struct.
 // Fields tht are already set will not be modified.
 func SetDefaultOpts(c *Opts) error {
 	c.OperatingSystem = DefaultOperatingSystem
 	c.Provider = ProviderName
-	c.NodeName = eci.NodeName
-	c.NodeId = eci.NodeId
+	c.NodeName = DefaultNodeName
 
 	c.InformerResyncPeriod = DefaultInformerResyncPeriod
 
-	c.TraceConfig.ServiceName = eci.NodeName
+	c.TraceConfig.Tags = make(map[string]string)
 	c.MetricsAddr = DefaultMetricsAddr
 	c.ListenPort = DefaultListenPort
 
 	c.PodSyncWorkers = DefaultPodSyncWorkers
-	i := os.Getenv("WORKERS")
-	if i != "" {
-		workers, _ := strconv.Atoi(i)
-		if workers != 0 {
-			c.PodSyncWorkers = workers
-		}
+
+	c.CreateAPILimit = cdsapi.Limit{QPS: DefaultCreateAPIQPS, Burst: DefaultCreateAPIBurst, MaxInFlight: DefaultCreateAPIMaxInFlight}
+	c.DeleteAPILimit = cdsapi.Limit{QPS: DefaultDeleteAPIQPS, Burst: DefaultDeleteAPIBurst, MaxInFlight: DefaultDeleteAPIMaxInFlight}
+	c.DescribeAPILimit = cdsapi.Limit{QPS: DefaultDescribeAPIQPS, Burst: DefaultDescribeAPIBurst, MaxInFlight: DefaultDescribeAPIMaxInFlight}
+
+	c.APITimeout = cdsapi.DefaultTimeout
+	c.APIActionTimeouts = make(map[string]string)
+	c.APIPool = cdsapi.DefaultPoolOptions()
+
+	retry := cdsapi.DefaultRetryPolicy()
+	c.APIMaxAttempts = retry.MaxAttempts
+	c.APIRetryBaseDelay = retry.BaseDelay
+	c.APIRetryMaxDelay = retry.MaxDelay
+	c.APIRetryJitter = retry.Jitter
+	c.APIRetryTransportErrors = retry.RetryableError != nil
+	for status := range retry.RetryableStatus {
+		c.APIRetryableStatuses = append(c.APIRetryableStatuses, status)
 	}
+	sort.Ints(c.APIRetryableStatuses)
+
+	c.DefaultInstanceSize = eci.DefaultInstanceSize.String()
+	c.NamespaceInstanceSizes = make(map[string]string)
+	c.PodOverhead = DefaultPodOverhead
+
+	c.DaemonSetPolicy = DefaultDaemonSetPolicy
+
+	capacity := eci.DefaultCapacity()
+	c.CapacityCPU = capacity.Cpu().String()
+	c.CapacityMemory = capacity.Memory().String()
+	c.CapacityPods = capacity.Pods().String()
+	c.CapacityEphemeralStorage = capacity.StorageEphemeral().String()
+
+	c.Reconcile = eci.ReconcileOptions{Interval: eci.DefaultReconcileInterval, GracePeriod: eci.DefaultOrphanGracePeriod}
 
 	c.KubeNamespace = DefaultKubeNamespace
-	c.Taints = []VKTaint{
-		VKTaint{
-			Key:    DefaultTaintKey,
-			Value:  ProviderName,
-			Effect: DefaultTaintEffect,
-		},
-	}
-
-	vkTaintStr := os.Getenv("TAINTS")
-	if vkTaintStr != "" {
-		var l []VKTaint
-		err := json.Unmarshal([]byte(vkTaintStr), &l)
-		if err == nil {
-			c.Taints = append(c.Taints, l...)
-		}
-	}
+	c.Taints = []VKTaint{defaultTaint()}
 
-	c.KubeConfigPath = getEnv("KUBECONFIG", DefaultKubeConfig)
+	c.KubeConfigPath = DefaultKubeConfig
 
-	c.CertPath = getEnv("CERT_PATH", DefaultCertPath)
+	c.CertPath = DefaultCertPath
 
-	c.KeyPath = getEnv("KEY_PATH", DefaultPathPath)
+	c.KeyPath = DefaultPathPath
 
 	c.Version = "v1.0.0"
 	return nil
 }
+
+// Validate reports every problem of the options at once, including those
+// found loading the configuration file and the environment.
+func (c *Opts) Validate() error {
+	errs := append([]error(nil), c.loadErrs...)
+	if ok := ps.ValidOperatingSystems[c.OperatingSystem]; !ok {
+		errs = append(errs, errdefs.InvalidInputf("operating system %q is not supported", c.OperatingSystem))
+	}
+	if c.PodSyncWorkers <= 0 {
+		errs = append(errs, errdefs.InvalidInput("pod sync workers must be greater than 0"))
+	}
+	for _, required := range []struct{ name, value string }{
+		{"node name (DEFAULT_NODE_NAME)", c.NodeName},
+		{"node id (DEFAULT_NODE_ID)", c.NodeId},
+		{"site id (SITE_ID)", c.SiteId},
+		{"OpenAPI endpoint (OPENAPI_HOST)", c.APIEndpoint},
+		{"access key id (CDS_ACCESS_KEY_ID)", c.AccessKeyID},
+		{"access key secret (CDS_ACCESS_KEY_SECRET)", c.AccessKeySecret},
+	} {
+		if required.value == "" {
+			errs = append(errs, errdefs.InvalidInputf("%s is required", required.name))
+		}
+	}
+	if c.APIMaxAttempts < 1 {
+		errs = append(errs, errdefs.InvalidInput("OpenAPI max attempts must be at least 1"))
+	}
+	if c.APIRetryBaseDelay < 0 || c.APIRetryMaxDelay < c.APIRetryBaseDelay {
+		errs = append(errs, errdefs.InvalidInputf("OpenAPI retry delays must satisfy 0 <= base (%v) <= max (%v)", c.APIRetryBaseDelay, c.APIRetryMaxDelay))
+	}
+	if c.APIRetryJitter < 0 || c.APIRetryJitter > 1 {
+		errs = append(errs, errdefs.InvalidInputf("OpenAPI retry jitter %v is not a fraction between 0 and 1", c.APIRetryJitter))
+	}
+	for _, status := range c.APIRetryableStatuses {
+		if status < 100 || status > 599 {
+			errs = append(errs, errdefs.InvalidInputf("retryable status %d is not a http status", status))
+		}
+	}
+	if _, err := getTaint(*c); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := getAPIActionTimeouts(*c); err != nil {
+		errs = append(errs, err)
+	}
+	if _, err := getProviderOptions(*c); err != nil {
+		errs = append(errs, err)
+	}
+	for _, e := range c.TraceExporters {
+		if _, ok := tracingExporters[e]; !ok && e != "zpages" {
+			errs = append(errs, errdefs.InvalidInputf("tracing exporter %q not found", e))
+		}
+	}
+	for k := range c.TraceConfig.Tags {
+		if reservedTagNames[k] {
+			errs = append(errs, errdefs.InvalidInputf("invalid trace tag %q, must not use a reserved tag key", k))
+		}
+	}
+	if _, err := getTraceSampler(c.TraceSampleRate); err != nil {
+		errs = append(errs, err)
+	}
+	return utilerrors.Flatten(utilerrors.NewAggregate(errs))
+}
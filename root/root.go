@@ -2,6 +2,7 @@ package root
 
 import (
 	"context"
+	"github.com/capitalonline/cds-virtual-kubelet/cdsapi"
 	"github.com/capitalonline/cds-virtual-kubelet/eci"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -9,11 +10,14 @@ import (
 	"github.com/virtual-kubelet/virtual-kubelet/log"
 	"github.com/virtual-kubelet/virtual-kubelet/manager"
 	"github.com/virtual-kubelet/virtual-kubelet/node"
-	ps "github.com/virtual-kubelet/virtual-kubelet/providers"
+	"go.opencensus.io/stats/view"
 	corev1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	kubeinformers "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
@@ -41,19 +45,23 @@ This allows users to schedule kubernetes workloads on nodes that aren't running
 		},
 	}
 
-	installFlags(cmd.Flags(), &c)
+	installFlags(cmd.PersistentFlags(), &c)
+	cmd.AddCommand(newConfigCommand(&c))
 	return cmd
 }
 
 func RunRootCommand(ctx context.Context, c Opts) error {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
-	if ok := ps.ValidOperatingSystems[c.OperatingSystem]; !ok {
-		return errdefs.InvalidInputf("operating system %q is not supported", c.OperatingSystem)
-	}
-	if c.PodSyncWorkers == 0 {
-		return errdefs.InvalidInput("pod sync workers must be greater than 0")
+	if err := c.Validate(); err != nil {
+		return err
 	}
+	eci.NodeName = c.NodeName
+	eci.NodeId = c.NodeId
+	eci.SiteId = c.SiteId
+	eci.ClusterId = c.ClusterId
+	eci.PrivateId = c.PrivateId
+
 	var taints []corev1.Taint
 
 	var err error
@@ -62,7 +70,7 @@ func RunRootCommand(ctx context.Context, c Opts) error {
 		return err
 	}
 
-	k8sClient, err := newClient(c.KubeConfigPath)
+	k8sClient, err := newClient(c.KubeConfigPath, c.MasterURI)
 	if err != nil {
 		return err
 	}
@@ -103,13 +111,58 @@ func RunRootCommand(ctx context.Context, c Opts) error {
 	if err := setupTracing(ctx, c); err != nil {
 		return err
 	}
+	if err := view.Register(cdsapi.Views...); err != nil {
+		return errors.Wrap(err, "could not register cdsapi metrics")
+	}
+	if err := view.Register(eci.Views...); err != nil {
+		return errors.Wrap(err, "could not register eci metrics")
+	}
+
+	actionTimeouts, err := getAPIActionTimeouts(c)
+	if err != nil {
+		return err
+	}
+	cdsClient := cdsapi.NewClient(
+		cdsapi.WithEndpoint(c.APIEndpoint),
+		cdsapi.WithCredentials(c.AccessKeyID, c.AccessKeySecret),
+		cdsapi.WithDefaultParams(map[string]string{
+			"CustomerId": c.CustomerID,
+			"UserId":     c.UserID,
+		}),
+		cdsapi.WithRateLimits(map[string]cdsapi.Limit{
+			cdsapi.CreateActionClass:   c.CreateAPILimit,
+			cdsapi.DeleteActionClass:   c.DeleteAPILimit,
+			cdsapi.DescribeActionClass: c.DescribeAPILimit,
+		}),
+		cdsapi.WithConnectionPool(c.APIPool),
+		cdsapi.WithRetryPolicy(getRetryPolicy(c)),
+		cdsapi.WithTimeout(c.APITimeout),
+		cdsapi.WithActionTimeouts(actionTimeouts),
+		cdsapi.WithRedactor(cdsapi.NewRedactor(append(cdsapi.DefaultRedactFields(), c.RedactFields...), c.UnredactFields)),
+	)
+
+	eb := record.NewBroadcaster()
+	eb.StartLogging(log.G(ctx).Infof)
+	eb.StartRecordingToSink(&corev1client.EventSinkImpl{Interface: k8sClient.CoreV1().Events(c.KubeNamespace)})
 
+	providerOpts, err := getProviderOptions(c)
+	if err != nil {
+		return err
+	}
+	providerOpts = append(providerOpts,
+		eci.WithReconcileOptions(c.Reconcile),
+		eci.WithPodsSynced(podInformer.Informer().HasSynced),
+		eci.WithEventRecorder(eb.NewRecorder(scheme.Scheme, corev1.EventSource{Component: path.Join(c.NodeName, "provider")})),
+	)
 	eciProvider, err := eci.NewECIProvider(
+		cdsClient,
 		rm,
+		k8sClient,
 		c.NodeName,
 		c.OperatingSystem,
-		os.Getenv("POD_IP"),
+		c.InternalIP,
 		c.ListenPort,
+		providerOpts...,
 	)
 	if err != nil {
 		return err
@@ -129,7 +182,7 @@ func RunRootCommand(ctx context.Context, c Opts) error {
 
 	pNode := NodeFromProvider(ctx, c.NodeName, taints, eciProvider, c.Version)
 	nodeRunner, err := node.NewNodeController(
-		node.NaiveNodeProvider{},
+		eci.NewNodeProvider(eciProvider, pNode),
 		pNode,
 		k8sClient.CoreV1().Nodes(),
 		node.WithNodeEnableLeaseV1Beta1(leaseClient, nil),
@@ -153,10 +206,6 @@ func RunRootCommand(ctx context.Context, c Opts) error {
 		log.G(ctx).Fatal(err)
 	}
 
-	eb := record.NewBroadcaster()
-	eb.StartLogging(log.G(ctx).Infof)
-	eb.StartRecordingToSink(&corev1client.EventSinkImpl{Interface: k8sClient.CoreV1().Events(c.KubeNamespace)})
-
 	pc, err := node.NewPodController(node.PodControllerConfig{
 		PodClient:       k8sClient.CoreV1(),
 		PodInformer:     podInformer,
@@ -203,6 +252,119 @@ func RunRootCommand(ctx context.Context, c Opts) error {
 	return nil
 }
 
+func getAPIActionTimeouts(c Opts) (map[string]time.Duration, error) {
+	var errs []error
+	timeouts := make(map[string]time.Duration, len(c.APIActionTimeouts))
+	for action, v := range c.APIActionTimeouts {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			errs = append(errs, errdefs.InvalidInputf("invalid timeout %q for action %s", v, action))
+			continue
+		}
+		timeouts[action] = d
+	}
+	if len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	return timeouts, nil
+}
+
+// getRetryPolicy is the default retry policy with the configured attempts,
+// delays, jitter and retryable statuses and errors.
+func getRetryPolicy(c Opts) cdsapi.RetryPolicy {
+	policy := cdsapi.DefaultRetryPolicy()
+	policy.MaxAttempts = c.APIMaxAttempts
+	policy.BaseDelay = c.APIRetryBaseDelay
+	policy.MaxDelay = c.APIRetryMaxDelay
+	policy.Jitter = c.APIRetryJitter
+	if !c.APIRetryTransportErrors {
+		policy.RetryableError = nil
+	}
+	policy.RetryableStatus = make(map[int]bool, len(c.APIRetryableStatuses))
+	for _, status := range c.APIRetryableStatuses {
+		policy.RetryableStatus[status] = true
+	}
+	return policy
+}
+
+// getProviderOptions parses the provider options, reporting every invalid one.
+func getProviderOptions(c Opts) ([]eci.ProviderOption, error) {
+	var errs []error
+	parseSize := func(v, what string) eci.InstanceSize {
+		size, err := eci.ParseInstanceSize(v)
+		if err != nil {
+			errs = append(errs, errdefs.InvalidInputf("%s: %v", what, err))
+		}
+		return size
+	}
+	sizes := make([]eci.InstanceSize, 0, len(c.InstanceSizes))
+	for _, v := range c.InstanceSizes {
+		sizes = append(sizes, parseSize(v, "instance size"))
+	}
+	defaultSize := parseSize(c.DefaultInstanceSize, "default instance size")
+	namespaceSizes := make(map[string]eci.InstanceSize, len(c.NamespaceInstanceSizes))
+	for ns, v := range c.NamespaceInstanceSizes {
+		namespaceSizes[ns] = parseSize(v, "instance size of namespace "+ns)
+	}
+	overhead := parseSize(c.PodOverhead, "pod overhead")
+	policy, err := eci.ParseDaemonSetPolicy(c.DaemonSetPolicy)
+	if err != nil {
+		errs = append(errs, errdefs.InvalidInput(err.Error()))
+	}
+	var allow labels.Selector
+	if c.DaemonSetAllowSelector != "" {
+		allow, err = labels.Parse(c.DaemonSetAllowSelector)
+		if err != nil {
+			errs = append(errs, errdefs.InvalidInputf("invalid DaemonSet allow selector: %v", err))
+		}
+	}
+	capacity, err := getCapacity(c)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return nil, utilerrors.Flatten(utilerrors.NewAggregate(errs))
+	}
+	return []eci.ProviderOption{
+		eci.WithCapacity(capacity),
+		eci.WithDaemonSetPolicy(policy, allow),
+		eci.WithStrictSecurityContext(c.RejectUnsupportedSecurity),
+		eci.WithInstanceSizes(sizes),
+		eci.WithDefaultInstanceSize(defaultSize, namespaceSizes),
+		eci.WithPodOverhead(overhead),
+		eci.WithStorageTypes(c.StorageTypes),
+	}, nil
+}
+
+// getCapacity parses the capacity flags, every quantity must be positive and
+// pods a whole number.
+func getCapacity(c Opts) (corev1.ResourceList, error) {
+	var errs []error
+	capacity := make(corev1.ResourceList, 4)
+	for name, v := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:              c.CapacityCPU,
+		corev1.ResourceMemory:           c.CapacityMemory,
+		corev1.ResourcePods:             c.CapacityPods,
+		corev1.ResourceEphemeralStorage: c.CapacityEphemeralStorage,
+	} {
+		q, err := resource.ParseQuantity(v)
+		switch {
+		case err != nil:
+			errs = append(errs, errdefs.InvalidInputf("invalid %s capacity %q: %v", name, v, err))
+		case q.Sign() <= 0:
+			errs = append(errs, errdefs.InvalidInputf("%s capacity %q must be positive", name, v))
+		case name == corev1.ResourcePods && q.MilliValue()%1000 != 0:
+			errs = append(errs, errdefs.InvalidInputf("pods capacity %q must be a whole number", v))
+		default:
+			capacity[name] = q
+		}
+	}
+	if len(errs) > 0 {
+		return nil, utilerrors.NewAggregate(errs)
+	}
+	return capacity, nil
+}
+
 func waitFor(ctx context.Context, time time.Duration, ready <-chan struct{}) error {
 	ctx, cancel := context.WithTimeout(ctx, time)
 	defer cancel()
@@ -217,7 +379,7 @@ func waitFor(ctx context.Context, time time.Duration, ready <-chan struct{}) err
 	}
 }
 
-func newClient(configPath string) (*kubernetes.Clientset, error) {
+func newClient(configPath, masterURI string) (*kubernetes.Clientset, error) {
 	var config *rest.Config
 
 	// Check if the kubeConfig file exists.
@@ -235,7 +397,7 @@ func newClient(configPath string) (*kubernetes.Clientset, error) {
 		}
 	}
 
-	if masterURI := os.Getenv("MASTER_URI"); masterURI != "" {
+	if masterURI != "" {
 		config.Host = masterURI
 	}
 
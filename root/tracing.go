@@ -4,7 +4,6 @@ import (
 	"context"
 	"net"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
@@ -35,9 +34,12 @@ func setupTracing(ctx context.Context, c Opts) error {
 	c.TraceConfig.Tags["operatingSystem"] = c.OperatingSystem
 	c.TraceConfig.Tags["provider"] = c.Provider
 	c.TraceConfig.Tags["nodeName"] = c.NodeName
+	if c.TraceConfig.ServiceName == "" {
+		c.TraceConfig.ServiceName = c.NodeName
+	}
 	for _, e := range c.TraceExporters {
 		if e == "zpages" {
-			setupZpages(ctx)
+			setupZpages(ctx, c.TraceConfig.ZpagesPort)
 			continue
 		}
 		exporter, err := GetTracingExporter(e, c.TraceConfig)
@@ -47,22 +49,9 @@ func setupTracing(ctx context.Context, c Opts) error {
 		octrace.RegisterExporter(exporter)
 	}
 	if len(c.TraceExporters) > 0 {
-		var s octrace.Sampler
-		switch strings.ToLower(c.TraceSampleRate) {
-		case "":
-		case "always":
-			s = octrace.AlwaysSample()
-		case "never":
-			s = octrace.NeverSample()
-		default:
-			rate, err := strconv.Atoi(c.TraceSampleRate)
-			if err != nil {
-				return errdefs.AsInvalidInput(errors.Wrap(err, "unsupported trace sample rate"))
-			}
-			if rate < 0 || rate > 100 {
-				return errdefs.AsInvalidInput(errors.Wrap(err, "trace sample rate must be between 0 and 100"))
-			}
-			s = octrace.ProbabilitySampler(float64(rate) / 100)
+		s, err := getTraceSampler(c.TraceSampleRate)
+		if err != nil {
+			return err
 		}
 
 		if s != nil {
@@ -77,10 +66,29 @@ func setupTracing(ctx context.Context, c Opts) error {
 	return nil
 }
 
-func setupZpages(ctx context.Context) {
-	p := os.Getenv("ZPAGES_PORT")
+// getTraceSampler parses the sample rate, nil keeps the default sampler.
+func getTraceSampler(rate string) (octrace.Sampler, error) {
+	switch strings.ToLower(rate) {
+	case "":
+		return nil, nil
+	case "always":
+		return octrace.AlwaysSample(), nil
+	case "never":
+		return octrace.NeverSample(), nil
+	}
+	percent, err := strconv.Atoi(rate)
+	if err != nil {
+		return nil, errdefs.AsInvalidInput(errors.Wrap(err, "unsupported trace sample rate"))
+	}
+	if percent < 0 || percent > 100 {
+		return nil, errdefs.InvalidInputf("trace sample rate %d must be between 0 and 100", percent)
+	}
+	return octrace.ProbabilitySampler(float64(percent) / 100), nil
+}
+
+func setupZpages(ctx context.Context, p string) {
 	if p == "" {
-		log.G(ctx).Error("Missing ZPAGES_PORT env var, cannot setup zpages endpoint")
+		log.G(ctx).Error("Missing ZPAGES_PORT env var or tracing.zpagesPort, cannot setup zpages endpoint")
 	}
 	listener, err := net.Listen("tcp", p)
 	if err != nil {
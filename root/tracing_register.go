@@ -8,6 +8,15 @@ import (
 type TracingExporterOptions struct {
 	Tags        map[string]string
 	ServiceName string
+
+	// Where the exporters send to
+	ZpagesPort          string
+	JaegerEndpoint      string
+	JaegerAgentEndpoint string
+	JaegerUser          string
+	JaegerPassword      string
+	OCAgentEndpoint     string
+	OCAgentInsecure     bool
 }
 
 var (
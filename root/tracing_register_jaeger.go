@@ -2,7 +2,6 @@ package root
 
 import (
 	"errors"
-	"os"
 
 	"contrib.go.opencensus.io/exporter/jaeger"
 	"go.opencensus.io/trace"
@@ -15,17 +14,17 @@ func init() {
 // NewJaegerExporter creates a new opencensus tracing exporter.
 func NewJaegerExporter(opts TracingExporterOptions) (trace.Exporter, error) {
 	jOpts := jaeger.Options{
-		Endpoint:      os.Getenv("JAEGER_ENDPOINT"),
-		AgentEndpoint: os.Getenv("JAEGER_AGENT_ENDPOINT"),
-		Username:      os.Getenv("JAEGER_USER"),
-		Password:      os.Getenv("JAEGER_PASSWORD"),
+		Endpoint:      opts.JaegerEndpoint,
+		AgentEndpoint: opts.JaegerAgentEndpoint,
+		Username:      opts.JaegerUser,
+		Password:      opts.JaegerPassword,
 		Process: jaeger.Process{
 			ServiceName: opts.ServiceName,
 		},
 	}
 
 	if jOpts.Endpoint == "" && jOpts.AgentEndpoint == "" {
-		return nil, errors.New("Must specify either JAEGER_ENDPOINT or JAEGER_AGENT_ENDPOINT")
+		return nil, errors.New("Must specify either JAEGER_ENDPOINT or JAEGER_AGENT_ENDPOINT, or tracing.jaeger.endpoint or tracing.jaeger.agentEndpoint")
 	}
 
 	for k, v := range opts.Tags {
@@ -1,8 +1,6 @@
 package root
 
 import (
-	"os"
-
 	"contrib.go.opencensus.io/exporter/ocagent"
 	"github.com/virtual-kubelet/virtual-kubelet/errdefs"
 	"go.opencensus.io/trace"
@@ -16,18 +14,14 @@ func init() {
 func NewOCAgentExporter(opts TracingExporterOptions) (trace.Exporter, error) {
 	agentOpts := append([]ocagent.ExporterOption{}, ocagent.WithServiceName(opts.ServiceName))
 
-	if endpoint := os.Getenv("OCAGENT_ENDPOINT"); endpoint != "" {
-		agentOpts = append(agentOpts, ocagent.WithAddress(endpoint))
+	if opts.OCAgentEndpoint != "" {
+		agentOpts = append(agentOpts, ocagent.WithAddress(opts.OCAgentEndpoint))
 	} else {
-		return nil, errdefs.InvalidInput("must set endpoint address in OCAGENT_ENDPOINT")
+		return nil, errdefs.InvalidInput("must set endpoint address in OCAGENT_ENDPOINT or tracing.ocagent.endpoint")
 	}
 
-	switch os.Getenv("OCAGENT_INSECURE") {
-	case "0", "no", "n", "off", "":
-	case "1", "yes", "y", "on":
+	if opts.OCAgentInsecure {
 		agentOpts = append(agentOpts, ocagent.WithInsecure())
-	default:
-		return nil, errdefs.InvalidInput("invalid value for OCAGENT_INSECURE")
 	}
 
 	return ocagent.NewExporter(agentOpts...)